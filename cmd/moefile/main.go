@@ -1,13 +1,28 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
 	"moefile/internal/cfg"
 	"moefile/internal/log"
 	"moefile/internal/meta"
+	"moefile/internal/metrics"
 	"moefile/internal/server"
+	"moefile/internal/tracing"
+	"moefile/pkg/listener"
+	"moefile/pkg/moefile"
+	"moefile/pkg/proxyproto"
+	"moefile/pkg/tlsreload"
+	"net"
+	"net/http"
 	"os"
-
-	"github.com/gin-gonic/gin"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 )
 
 var (
@@ -19,36 +34,333 @@ var (
 	DefaultFlagTrustProxies = "off"
 )
 
+const (
+	ExitOK     = 0
+	ExitSetup  = 1 // invalid config or failed to setup
+	ExitServe  = 2 // a listener failed while serving
+	ExitForced = 3 // in-flight requests were cut off after the grace period
+)
+
+const tracingShutdownTimeout = 5 * time.Second
+
 func main() {
 	app := cfg.NewAppConfigFromFlag()
-	log.Setup(app.ParseLogLevel())
+	err := setupLogger(app)
+	if err != nil {
+		log.T("main").Errf("Failed to setup logger: %v", err)
+		os.Exit(ExitSetup)
+	}
 	log.T("main").Inff("%s %s (Build %s)", meta.AppName, meta.AppVersion, meta.BuildTimestamp)
 	log.T("main").Inff("Copyrigyt (c) %s %s, distributed under the %s license",
 		meta.AppCopyRight, meta.AppAuthor, meta.AppLicense)
 	log.T("main").Inff(" - Build mode: %s", meta.BuildMode)
 	log.T("main").Inff(" - Server name: %s", app.ServerName)
+	log.T("main").Inff(" - Base path: %s/", strings.TrimSuffix(app.BasePath, "/"))
+	log.T("main").Inff(" - Root: %q (precedence: %s)", app.RootPaths, app.Precedence)
 	log.T("main").Inff(" - Log level: %s (0x%02x)", app.LogLevel, app.ParseLogLevel())
+	log.T("main").Inff(" - Log tag levels: %s", log.AppLogger.GetTagLevels())
+	log.T("main").Inff(" - Log format: %s", app.LogFormat)
+	log.T("main").Inff(" - Log queue: %d", app.LogQueue)
+	log.T("main").Inff(" - Log sinks: %s", app.LogSinks)
+	log.T("main").Inff(" - Access log: %s", app.AccessFormat)
 
-	log.SetupGin1()
-	if meta.BuildMode == "production" {
-		gin.SetMode(gin.ReleaseMode)
-	} else {
-		gin.SetMode(gin.DebugMode)
+	err = tracing.Setup(app.Trace, app.TraceEndpoint, app.TraceSample)
+	if err != nil {
+		log.T("main").Errf("Failed to setup tracing: %v", err)
+		log.Close()
+		os.Exit(ExitSetup)
 	}
+	log.T("main").Inff(" - Trace exporter: %s", app.Trace)
 
-	e := gin.New()
-	log.SetupGin2(e)
-	err := e.SetTrustedProxies(app.TrustedProxiesList())
+	h, err := server.New(app)
+	if err != nil {
+		log.T("main").Errf("Failed to setup server: %v", err)
+		log.Close()
+		os.Exit(ExitSetup)
+	}
+	services, err := setupServices(app, h)
 	if err != nil {
-		log.T("main").Errf("Failed to set trusted proxies: %v", err)
-		os.Exit(1)
+		log.T("main").Errf("Failed to setup listeners: %v", err)
+		log.Close()
+		os.Exit(ExitSetup)
+	}
+
+	code := serve(app, services, h)
+	log.T("main").Inff("Server exited with code %d", code)
+	log.Close()
+	os.Exit(code)
+}
+
+type service struct {
+	*http.Server
+	name      string
+	tls       bool
+	listeners []net.Listener
+}
+
+func setupServices(app cfg.AppConfig, h *moefile.Handler) ([]*service, error) {
+	socketMode, err := app.ParseSocketMode()
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := app.ParseListenAddrs()
+	if err != nil {
+		return nil, err
+	}
+	trusted, err := app.TrustedProxyPrefixes()
+	if err != nil {
+		return nil, err
+	}
+
+	handler := &http.Server{Handler: log.AccessLog(h, trusted), ConnState: h.ConnState}
+	err = setupTLS(app, handler)
+	if err != nil {
+		return nil, fmt.Errorf("tls: %w", err)
+	}
+	services := make([]*service, 0)
+	srv, err := newService("Server", handler, addrs, socketMode)
+	if err != nil {
+		return nil, err
+	}
+	if app.ProxyProtocol {
+		for i, l := range srv.listeners {
+			srv.listeners[i] = proxyproto.NewListener(l, trusted)
+		}
+		log.T("main").Inff(" - PROXY protocol: accepted from %s", app.TrustedProxies)
+	}
+	services = append(services, srv)
+
+	if app.IsTLS() && app.TLSRedirect != "" {
+		redirectAddrs, err := listener.Parse(app.TLSRedirect)
+		if err != nil {
+			return nil, err
+		}
+		port, err := redirectPort(app, addrs)
+		if err != nil {
+			return nil, err
+		}
+		log.T("main").Inff(" - HTTPS redirect: %s -> port %s", app.TLSRedirect, port)
+		handler := &http.Server{Handler: server.RedirectHandler(port)}
+		srv, err := newService("HTTPS redirect", handler, redirectAddrs, socketMode)
+		if err != nil {
+			return nil, err
+		}
+		services = append(services, srv)
+	}
+
+	if app.Metrics {
+		metricsAddrs, handler, err := setupMetrics(app)
+		if err != nil {
+			return nil, err
+		}
+		if handler != nil {
+			srv, err := newService("Metrics server", handler, metricsAddrs, socketMode)
+			if err != nil {
+				return nil, err
+			}
+			services = append(services, srv)
+		}
+	}
+	return services, nil
+}
+
+func newService(name string, srv *http.Server, addrs []listener.Addr, socketMode fs.FileMode) (*service, error) {
+	listeners, err := listener.Listen(addrs, socketMode)
+	if err != nil {
+		return nil, err
+	}
+	// http.Server may populate TLSConfig for HTTP/2 when serving, so decide it before
+	tls := srv.TLSConfig != nil
+	scheme := map[bool]string{true: "https", false: "http"}[tls]
+	for _, l := range listeners {
+		addr := listener.Addr{Network: l.Addr().Network(), Address: l.Addr().String()}
+		log.T("main").Inff("%s is listening on %s", name, addr.URL(scheme))
+	}
+	return &service{Server: srv, name: name, tls: tls, listeners: listeners}, nil
+}
+
+// redirectPort is the configured public HTTPS port, or the port of the first TCP listen address.
+// Systemd and unix sockets are usually behind a proxy with another port, so it is not guessed from them.
+func redirectPort(app cfg.AppConfig, addrs []listener.Addr) (string, error) {
+	if app.TLSPublicPort != "" {
+		port, err := strconv.ParseUint(app.TLSPublicPort, 10, 16)
+		if err != nil || port == 0 {
+			return "", fmt.Errorf("invalid -tls-port: %s", app.TLSPublicPort)
+		}
+		return app.TLSPublicPort, nil
+	}
+	for _, addr := range addrs {
+		if addr.Network != "tcp" {
+			continue
+		}
+		_, port, err := net.SplitHostPort(addr.Address)
+		if err == nil {
+			return port, nil
+		}
+	}
+	return "", errors.New("-tls-redirect requires -tls-port when there is no TCP listen address")
+}
+
+func serve(app cfg.AppConfig, services []*service, h *moefile.Handler) int {
+	errs := make(chan error)
+	for _, srv := range services {
+		for _, l := range srv.listeners {
+			go func() {
+				var err error
+				if srv.tls {
+					err = srv.ServeTLS(l, "", "")
+				} else {
+					err = srv.Serve(l)
+				}
+				if !errors.Is(err, http.ErrServerClosed) {
+					errs <- fmt.Errorf("%s: %w", srv.name, err)
+				}
+			}()
+		}
+	}
+
+	sig := make(chan os.Signal, 2)
+	signal.Notify(sig, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case err := <-errs:
+		log.T("main").Errf("Failed to serve: %v", err)
+		return ExitServe
+	case s := <-sig:
+		log.T("main").Inff("Received %s, shutting down (drain delay: %s, grace period: %s)", s, app.DrainDelay, app.GracePeriod)
+	}
+	return shutdown(app, sig, services, h)
+}
+
+// shutdown fails readiness and keeps serving for the drain delay, then stops accepting new connections and waits for in-flight requests,
+// the connections are closed forcibly when the grace period exceeds or another signal is received
+func shutdown(app cfg.AppConfig, sig <-chan os.Signal, services []*service, h *moefile.Handler) int {
+	h.Drain()
+	if app.DrainDelay > 0 {
+		select {
+		case <-time.After(app.DrainDelay):
+		case s := <-sig:
+			log.T("main").Wrnf("Received %s again, skipping drain delay", s)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if app.GracePeriod > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), app.GracePeriod)
+	}
+	defer cancel()
+	go func() {
+		select {
+		case s := <-sig:
+			log.T("main").Wrnf("Received %s again, forcing shutdown", s)
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	code := ExitOK
+	for _, srv := range services {
+		err := srv.Shutdown(ctx)
+		if err != nil {
+			log.T("main").Wrnf("Unable to drain %s, closing %d connections: %v", srv.name, h.OpenConnections(), err)
+			//nolint:errcheck
+			srv.Close()
+			code = ExitForced
+		}
+	}
+
+	traceCtx, traceCancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+	defer traceCancel()
+	err := tracing.Shutdown(traceCtx)
+	if err != nil {
+		log.T("main").Wrnf("Failed to shutdown tracing: %v", err)
+	}
+	return code
+}
+
+func setupLogger(app cfg.AppConfig) error {
+	log.Setup(app.ParseLogLevel())
+	logFormat, err := app.ParseLogFormat()
+	if err != nil {
+		return err
+	}
+	log.SetupFormat(logFormat)
+
+	err = log.SetupTagLevels(app.LogTagLevels, app.LogLevelFile)
+	if err != nil {
+		return err
+	}
+	log.WatchTagLevels(app.LogTagLevels, app.LogLevelFile)
+
+	logRotate, err := app.ParseLogRotate()
+	if err != nil {
+		return err
+	}
+	logColor, err := app.ParseLogColor()
+	if err != nil {
+		return err
+	}
+	err = log.SetupSinks(app.LogSinks, logRotate, logColor)
+	if err != nil {
+		return err
+	}
+	err = log.SetupAccessLog(app.AccessFormat, app.AccessSinks, app.ServerName, logRotate, app.LogQueue)
+	if err != nil {
+		return err
+	}
+
+	log.SetupAsync(app.LogQueue)
+	return nil
+}
+
+func setupTLS(app cfg.AppConfig, srv *http.Server) error {
+	if !app.IsTLS() {
+		if app.TLSClientPaths != "" || app.TLSClientCA != "" || app.TLSRedirect != "" || app.TLSPublicPort != "" {
+			return errors.New("client certificates and HTTPS redirect require -tls-cert and -tls-key")
+		}
+		return nil
+	}
+
+	clientAuth, err := app.ParseTLSClientAuth()
+	if err != nil {
+		return err
+	}
+	if clientAuth == tlsreload.ClientAuthOff && app.TLSClientPaths != "" {
+		return errors.New("-tls-clientpaths requires a client certificate mode other than off")
+	}
+	reloader, err := tlsreload.New(app.TLSCert, app.TLSKey, app.TLSClientCA, clientAuth)
+	if err != nil {
+		return err
+	}
+	reloader.OnReload = func() {
+		log.T("main/tls").Inff("TLS certificates reloaded")
+	}
+	reloader.OnError = func(err error) {
+		log.T("main/tls").Errf("Unable to reload TLS certificates, keeping the current ones: %v", err)
+	}
+	reloader.Watch(tlsreload.DefaultInterval)
+	srv.TLSConfig = reloader.TLSConfig()
+
+	log.T("main").Inff(" - TLS certificate: %s", app.TLSCert)
+	if clientAuth != tlsreload.ClientAuthOff {
+		log.T("main").Inff(" - TLS client CA: %s (%s)", app.TLSClientCA, clientAuth)
+		log.T("main").Inff(" - TLS client paths: %s", app.TLSClientPathList())
+	}
+	return nil
+}
+
+// setupMetrics returns the metrics server of -metricsaddr, without it the handler serves them at ?_/metrics
+func setupMetrics(app cfg.AppConfig) ([]listener.Addr, *http.Server, error) {
+	handler := metrics.Registry.Handler()
+	if app.MetricsAddr == "" {
+		log.T("main").Inff("Metrics are exposed at /?_/metrics to loopback clients and trusted proxies")
+		return nil, nil, nil
 	}
 
-	log.T("main").Inff("Server is listening on http://%s", app.ListenAddr)
-	server.Setup(app, e)
-	err = e.Run(app.ListenAddr)
+	addrs, err := listener.Parse(app.MetricsAddr)
 	if err != nil {
-		log.T("main").Errf("Failed to start server: %v", err)
-		os.Exit(1)
+		return nil, nil, fmt.Errorf("metrics: %w", err)
 	}
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", handler)
+	return addrs, &http.Server{Handler: mux}, nil
 }
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"moefile/pkg/compress"
+)
+
+func main() {
+	flag.Parse()
+	if flag.NArg() != 1 {
+		println("Usage: precompress <dir>")
+		os.Exit(2)
+	}
+
+	err := filepath.WalkDir(flag.Arg(0), func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !compress.IsCompressibleName(path) {
+			return err
+		}
+		return precompress(path)
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+func precompress(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if len(data) < compress.MinSize {
+		return nil
+	}
+
+	for _, enc := range compress.Preference {
+		buf := new(bytes.Buffer)
+		w, err := compress.NewWriter(enc, buf)
+		if err != nil {
+			return err
+		}
+		if _, err = w.Write(data); err != nil {
+			return err
+		}
+		if err = w.Close(); err != nil {
+			return err
+		}
+
+		// Not worth serving a sidecar that saves almost nothing
+		if buf.Len() >= len(data)*9/10 {
+			continue
+		}
+
+		name := path + compress.SidecarExt[enc]
+		println(fmt.Sprintf("Writing: %s [%d -> %d bytes]", name, len(data), buf.Len()))
+		if err = os.WriteFile(name, buf.Bytes(), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -2,11 +2,17 @@ package cfg
 
 import (
 	"flag"
+	"fmt"
+	"io/fs"
+	"net/netip"
 	"strings"
 	"time"
 
 	"moefile/internal/meta"
+	"moefile/pkg/backend"
+	"moefile/pkg/listener"
 	"moefile/pkg/logger"
+	"moefile/pkg/tlsreload"
 )
 
 const (
@@ -18,22 +24,87 @@ var (
 	AppIsDevelopmentMode     = meta.BuildMode == "development"
 	AppDefaultServerName     = meta.AppName
 	AppDefaultListenAddr     = "0.0.0.0:3328" // 0x0d00 - Ciallo～(∠・ω< )⌒★
-	AppDefaultRootPath       = ""
+	AppDefaultSocketMode     = "0660"
+	AppDefaultBasePath       = ""
+	AppDefaultPrecedence     = "first"
+	AppDefaultShowSources    = false
+	AppDefaultStatus         = false
 	AppDefaultLogLevel       = map[bool]string{true: "dbg", false: "inf"}[AppIsDevelopmentMode]
+	AppDefaultLogTagLevels   = ""
+	AppDefaultLogLevelFile   = ""
+	AppDefaultLogQueue       = 0
+	AppDefaultLogSinks       = "stdout"
+	AppDefaultLogMaxSize     = 100
+	AppDefaultLogRotate      = "daily"
+	AppDefaultLogKeep        = 7
+	AppDefaultLogCompress    = true
+	AppDefaultAccessFormat   = "default"
+	AppDefaultAccessSinks    = "stdout"
+	AppDefaultLogFormat      = "text"
+	AppDefaultLogColor       = "auto"
 	AppDefaultAllowedOrigins = map[bool]string{true: Wildcard, false: ""}[AppIsDevelopmentMode]
 	AppDefaultTrustedProxies = map[bool]string{true: WildcardCIDRListString, false: "127.0.0.1"}[AppIsDevelopmentMode]
 	AppDefaultXMLIndent      = AppIsDevelopmentMode
-	AppDefaultBuildTime      = parseBuildTime()
+	AppDefaultProxyProtocol  = false
+	AppDefaultCompression    = true
+	AppDefaultMetrics        = false
+	AppDefaultMetricsAddr    = ""
+	AppDefaultTrace          = "off"
+	AppDefaultTraceEndpoint  = ""
+	AppDefaultTraceSample    = 1.0
+	AppDefaultGracePeriod    = 30 * time.Second
+	AppDefaultDrainDelay     = 5 * time.Second
+	AppDefaultTLSCert        = ""
+	AppDefaultTLSKey         = ""
+	AppDefaultTLSClientCA    = ""
+	AppDefaultTLSClientAuth  = ""
+	AppDefaultTLSClientPaths = ""
+	AppDefaultTLSRedirect    = ""
+	AppDefaultTLSPublicPort  = ""
+	AppDefaultBuildTime      = meta.BuildTime
 )
 
 type AppConfig struct {
 	ServerName     string
 	ListenAddr     string
-	RootPath       string
+	SocketMode     string
+	RootPaths      []string
+	BasePath       string
+	Precedence     string
+	ShowSources    bool
+	Status         bool
 	LogLevel       string
+	LogTagLevels   string
+	LogLevelFile   string
+	LogQueue       int
+	LogSinks       string
+	LogMaxSize     int
+	LogRotate      string
+	LogKeep        int
+	LogCompress    bool
+	AccessFormat   string
+	AccessSinks    string
+	LogFormat      string
+	LogColor       string
 	AllowedOrigins string
 	TrustedProxies string
+	ProxyProtocol  bool
 	XMLIndent      bool
+	Compression    bool
+	Metrics        bool
+	MetricsAddr    string
+	Trace          string
+	TraceEndpoint  string
+	TraceSample    float64
+	GracePeriod    time.Duration
+	DrainDelay     time.Duration
+	TLSCert        string
+	TLSKey         string
+	TLSClientCA    string
+	TLSClientAuth  string
+	TLSClientPaths string
+	TLSRedirect    string
+	TLSPublicPort  string
 }
 
 func (cfg *AppConfig) IsDevelopmentMode() bool {
@@ -55,6 +126,85 @@ func (cfg *AppConfig) ParseLogLevel() logger.LogLevel {
 	}
 }
 
+func (cfg *AppConfig) ParseLogFormat() (logger.Formatter, error) {
+	return logger.NewFormatter(cfg.LogFormat)
+}
+
+func (cfg *AppConfig) ParseLogColor() (logger.ColorMode, error) {
+	return logger.ParseColorMode(cfg.LogColor)
+}
+
+func (cfg *AppConfig) ParseLogRotate() (logger.RotateOptions, error) {
+	opts := logger.RotateOptions{
+		MaxSize:    int64(cfg.LogMaxSize) * 1024 * 1024,
+		MaxBackups: cfg.LogKeep,
+		Compress:   cfg.LogCompress,
+	}
+	switch strings.ToLower(cfg.LogRotate) {
+	case "hourly":
+		opts.Interval = time.Hour
+	case "daily":
+		opts.Interval = 24 * time.Hour
+	case "weekly":
+		opts.Interval = 7 * 24 * time.Hour
+	case "", "off":
+	default:
+		return opts, fmt.Errorf("unknown log rotate interval: %s", cfg.LogRotate)
+	}
+	return opts, nil
+}
+
+// ParseListenAddrs uses the sockets passed by systemd instead of the default address when started by socket activation
+func (cfg *AppConfig) ParseListenAddrs() ([]listener.Addr, error) {
+	if cfg.ListenAddr == AppDefaultListenAddr && listener.IsActivated() {
+		return listener.Parse(listener.PrefixSystemd)
+	}
+	return listener.Parse(cfg.ListenAddr)
+}
+
+func (cfg *AppConfig) ParseSocketMode() (fs.FileMode, error) {
+	return listener.ParseSocketMode(cfg.SocketMode)
+}
+
+func (cfg *AppConfig) ParsePrecedence() (backend.Precedence, error) {
+	return backend.ParsePrecedence(cfg.Precedence)
+}
+
+func (cfg *AppConfig) IsTLS() bool {
+	return cfg.TLSCert != ""
+}
+
+func (cfg *AppConfig) ParseTLSClientAuth() (tlsreload.ClientAuth, error) {
+	if cfg.TLSClientAuth == "" && cfg.TLSClientCA != "" {
+		return tlsreload.ClientAuthOptional, nil
+	}
+	return tlsreload.ParseClientAuth(strings.ToLower(cfg.TLSClientAuth))
+}
+
+// stringList is a flag which may be repeated, the values are kept as they are, commas included
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(v string) error {
+	*l = append(*l, v)
+	return nil
+}
+
+func (cfg *AppConfig) TLSClientPathList() []string {
+	paths := make([]string, 0)
+	for _, path := range strings.Split(cfg.TLSClientPaths, ",") {
+		path = strings.TrimSpace(path)
+		if path == "" {
+			continue
+		}
+		paths = append(paths, "/"+strings.Trim(path, "/"))
+	}
+	return paths
+}
+
 func (cfg *AppConfig) TrustedProxiesList() []string {
 	trustedProxies := cfg.TrustedProxies
 	if trustedProxies == Wildcard {
@@ -63,58 +213,118 @@ func (cfg *AppConfig) TrustedProxiesList() []string {
 	return strings.Split(trustedProxies, ",")
 }
 
-func (cfg *AppConfig) IsAllowedOrigin(origin string) bool {
-	allows := strings.Split(strings.TrimSpace(cfg.AllowedOrigins), ",")
-	for _, allow := range allows {
-		allow = strings.TrimSpace(allow)
-		if allow == Wildcard || allow == origin {
-			return true
+func (cfg *AppConfig) TrustedProxyPrefixes() ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0)
+	for _, proxy := range cfg.TrustedProxiesList() {
+		proxy = strings.TrimSpace(proxy)
+		if proxy == "" {
+			continue
+		}
+		if strings.Contains(proxy, "/") {
+			prefix, err := netip.ParsePrefix(proxy)
+			if err != nil {
+				return nil, err
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
 		}
+		addr, err := netip.ParseAddr(proxy)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
 	}
-	return false
+	return prefixes, nil
+}
+
+func (cfg *AppConfig) AllowedOriginList() []string {
+	return strings.Split(strings.TrimSpace(cfg.AllowedOrigins), ",")
 }
 
 func NewAppConfigFromFlag() AppConfig {
 	serverName := flag.String("server", AppDefaultServerName, "app name")
-	listenAddr := flag.String("listen", AppDefaultListenAddr, "listen address")
-	rootPath := flag.String("root", AppDefaultRootPath, "server web root path")
+	listenAddr := flag.String("listen", AppDefaultListenAddr, "listen addresses split by comma: <host>:<port>, unix:<path>, systemd[:<name>]")
+	socketMode := flag.String("socketmode", AppDefaultSocketMode, "permissions of unix sockets, in octal")
+	rootPaths := stringList{}
+	flag.Var(&rootPaths, "root", "server web root, a directory, a .zip/.tar/.tar.gz archive, optionally prefixed by dir:, zip: or tar:, repeat it to merge several roots")
+	precedence := flag.String("precedence", AppDefaultPrecedence, "layer providing a name found in several roots: first, last, newest, largest")
+	showSources := flag.Bool("sources", AppDefaultShowSources, "show the root providing each entry in listings when several roots are merged")
+	status := flag.Bool("status", AppDefaultStatus, "serve the status page at /?_/status to loopback clients and trusted proxies")
+	basePath := flag.String("base-path", AppDefaultBasePath, "public URL path the server is mounted at, e.g. /files")
 	logLevel := flag.String("level", AppDefaultLogLevel, "log level, available values: dbg, inf, wrn, err")
+	logTagLevels := flag.String("levels", AppDefaultLogTagLevels, "log level overrides by tag prefix, e.g. server/player=dbg,url=wrn")
+	logLevelFile := flag.String("levelfile", AppDefaultLogLevelFile, "file of log level overrides by tag prefix, reloaded on SIGHUP")
+	logFormat := flag.String("logformat", AppDefaultLogFormat, "log format, available values: text, json")
+	logColor := flag.String("color", AppDefaultLogColor, "colored log output, available values: auto, always, never")
+	logQueue := flag.Int("logqueue", AppDefaultLogQueue, "async log queue size, 0 to write logs synchronously")
+	logSinks := flag.String("logto", AppDefaultLogSinks, "log sinks split by comma: stdout, stderr, file:<path>, syslog[:<socket>], each can be prefixed by '<level>:'")
+	logMaxSize := flag.Int("logmaxsize", AppDefaultLogMaxSize, "rotate log files larger than this size in MiB, 0 to disable")
+	logRotate := flag.String("logrotate", AppDefaultLogRotate, "rotate log files by time, available values: hourly, daily, weekly, off")
+	logKeep := flag.Int("logkeep", AppDefaultLogKeep, "number of rotated log files to keep, 0 to keep all")
+	logCompress := flag.Bool("logcompress", AppDefaultLogCompress, "gzip rotated log files")
+	accessFormat := flag.String("accessformat", AppDefaultAccessFormat, "access log format, available values: default, common, combined, w3c, json, s3")
+	accessSinks := flag.String("accesslog", AppDefaultAccessSinks, "access log sinks split by comma, same as -logto, not used by the default format")
 	allowedOrigin := flag.String("origins", AppDefaultAllowedOrigins, "allowed CROS origins, split by comma")
 	trustedProxies := flag.String("proxies", AppDefaultTrustedProxies, "trusted proxies, split by comma, or '*' for all")
+	proxyProtocol := flag.Bool("proxyproto", AppDefaultProxyProtocol, "accept PROXY protocol v1/v2 headers from trusted proxies")
 	xmlIndent := flag.Bool("xmltab", AppDefaultXMLIndent, "pretty print JSON/XML in response")
+	metrics := flag.Bool("metrics", AppDefaultMetrics, "expose Prometheus metrics at /?_/metrics to loopback clients and trusted proxies, or at /metrics of -metricsaddr")
+	metricsAddr := flag.String("metricsaddr", AppDefaultMetricsAddr, "listen address for metrics, empty to use the server listener")
+	trace := flag.String("trace", AppDefaultTrace, "trace exporter, available values: off, stdout, otlp")
+	traceEndpoint := flag.String("traceendpoint", AppDefaultTraceEndpoint, "OTLP/HTTP endpoint, defaults to $OTEL_EXPORTER_OTLP_ENDPOINT")
+	traceSample := flag.Float64("tracesample", AppDefaultTraceSample, "ratio of traces to sample, from 0 to 1")
+	gracePeriod := flag.Duration("grace", AppDefaultGracePeriod, "time to wait for in-flight requests on shutdown, 0 to wait forever")
+	drainDelay := flag.Duration("drain-delay", AppDefaultDrainDelay, "time to keep accepting requests on shutdown after readiness fails, so load balancers see it first")
+	tlsCert := flag.String("tls-cert", AppDefaultTLSCert, "TLS certificate file, reloaded when changed")
+	tlsKey := flag.String("tls-key", AppDefaultTLSKey, "TLS private key file, reloaded when changed")
+	tlsClientCA := flag.String("tls-ca", AppDefaultTLSClientCA, "CA bundle to verify client certificates")
+	tlsClientAuth := flag.String("tls-client", AppDefaultTLSClientAuth, "client certificate mode: off, optional, require, defaults to optional when -tls-ca is set")
+	tlsClientPaths := flag.String("tls-clientpaths", AppDefaultTLSClientPaths, "path prefixes which require a verified client certificate, split by comma")
+	tlsRedirect := flag.String("tls-redirect", AppDefaultTLSRedirect, "listen address which redirects HTTP requests to HTTPS, empty to disable")
+	tlsPublicPort := flag.String("tls-port", AppDefaultTLSPublicPort, "public HTTPS port of redirects, defaults to the port of the first TCP listen address")
+	compression := flag.Bool("compress", AppDefaultCompression, "compress listings and serve precompressed .gz/.br/.zst sidecars")
 
 	flag.Parse()
 	return AppConfig{
 		ServerName:     *serverName,
 		ListenAddr:     *listenAddr,
-		RootPath:       *rootPath,
+		SocketMode:     *socketMode,
+		RootPaths:      rootPaths,
+		BasePath:       *basePath,
+		Precedence:     *precedence,
+		ShowSources:    *showSources,
+		Status:         *status,
 		LogLevel:       *logLevel,
+		LogTagLevels:   *logTagLevels,
+		LogLevelFile:   *logLevelFile,
+		LogQueue:       *logQueue,
+		LogSinks:       *logSinks,
+		LogMaxSize:     *logMaxSize,
+		LogRotate:      *logRotate,
+		LogKeep:        *logKeep,
+		LogCompress:    *logCompress,
+		AccessFormat:   *accessFormat,
+		AccessSinks:    *accessSinks,
+		LogFormat:      *logFormat,
+		LogColor:       *logColor,
 		AllowedOrigins: *allowedOrigin,
 		TrustedProxies: *trustedProxies,
+		ProxyProtocol:  *proxyProtocol,
 		XMLIndent:      *xmlIndent,
+		Compression:    *compression,
+		Metrics:        *metrics,
+		MetricsAddr:    *metricsAddr,
+		Trace:          *trace,
+		TraceEndpoint:  *traceEndpoint,
+		TraceSample:    *traceSample,
+		GracePeriod:    *gracePeriod,
+		DrainDelay:     *drainDelay,
+		TLSCert:        *tlsCert,
+		TLSKey:         *tlsKey,
+		TLSClientCA:    *tlsClientCA,
+		TLSClientAuth:  *tlsClientAuth,
+		TLSClientPaths: *tlsClientPaths,
+		TLSRedirect:    *tlsRedirect,
+		TLSPublicPort:  *tlsPublicPort,
 	}
 }
-
-func parseBuildTime() time.Time {
-	t, err := time.Parse(time.RFC3339, meta.BuildTimestamp)
-	if err == nil {
-		return t
-	}
-
-	t, err = time.Parse(time.RFC3339Nano, meta.BuildTimestamp)
-	if err == nil {
-		return t
-	}
-
-	t, err = time.Parse("2006-01-02 15:04:05 -0700 MST", meta.BuildTimestamp)
-	if err == nil {
-		return t
-	}
-
-	t, err = time.Parse("2006-01-02 15:04:05 -0700", meta.BuildTimestamp)
-	if err == nil {
-		return t
-	}
-
-	return time.Now()
-}
@@ -0,0 +1,115 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"moefile/internal/meta"
+	"moefile/pkg/httpx"
+	"moefile/pkg/logger"
+)
+
+const AccessFormatDefault = "default"
+
+var (
+	accessFormat logger.AccessFormat
+	accessWriter io.Writer
+	accessBucket string
+)
+
+// SetupAccessLog writes access logs in a standard format to their own sinks,
+// otherwise access logs are written by the application logger with the "http" tag
+func SetupAccessLog(format, sinks, bucket string, rotate logger.RotateOptions, queueSize int) error {
+	if format == "" || format == AccessFormatDefault {
+		return nil
+	}
+
+	f, err := logger.NewAccessFormat(format, fmt.Sprintf("%s/%s", meta.AppName, meta.AppVersion))
+	if err != nil {
+		return err
+	}
+
+	header := f.Header()
+	if header != nil {
+		// W3C parsers read the directives at the top of each file, so rotated files need them too
+		rotate.OnOpen = func(w io.Writer) error {
+			_, err := w.Write(f.Header())
+			return err
+		}
+	}
+	routes, err := ParseSinks(sinks, rotate)
+	if err != nil {
+		return err
+	}
+	if len(routes) == 0 {
+		return fmt.Errorf("no access log sink configured")
+	}
+	for _, r := range routes {
+		if header != nil && !isRotatingFile(r.Writer) {
+			//nolint:errcheck
+			r.Writer.Write(header)
+		}
+	}
+
+	var w io.Writer = logger.NewLevelRouter(routes...)
+	if len(routes) == 1 {
+		w = routes[0].Writer
+	}
+	if queueSize > 0 {
+		w = logger.NewAsyncWriter(w, queueSize)
+	}
+
+	accessFormat, accessWriter, accessBucket = f, w, bucket
+	return nil
+}
+
+func isRotatingFile(w io.Writer) bool {
+	for {
+		if _, ok := w.(*logger.RotatingFile); ok {
+			return true
+		}
+		u, ok := w.(interface{ Unwrap() io.Writer })
+		if !ok {
+			return false
+		}
+		w = u.Unwrap()
+	}
+}
+
+func closeAccessLog() {
+	if accessWriter == nil {
+		return
+	}
+	if aw, ok := accessWriter.(*logger.AsyncWriter); ok {
+		aw.Close()
+		closeSink(aw.Unwrap())
+		return
+	}
+	closeSink(accessWriter)
+}
+
+func writeAccessRecord(w *httpx.ResponseWriter, r *http.Request, start time.Time, clientIP, requestID string) {
+	user, _, _ := r.BasicAuth()
+	record := logger.AccessRecord{
+		Time:      start,
+		ClientIP:  clientIP,
+		User:      user,
+		Method:    r.Method,
+		Path:      r.URL.EscapedPath(),
+		Query:     r.URL.RawQuery,
+		Proto:     r.Proto,
+		Host:      r.Host,
+		Status:    w.Status(),
+		Bytes:     w.Size(),
+		Latency:   time.Since(start),
+		Referer:   r.Referer(),
+		UserAgent: r.UserAgent(),
+		Range:     r.Header.Get("Range"),
+		Bucket:    accessBucket,
+		RequestID: requestID,
+	}
+	//nolint:errcheck
+	accessWriter.Write(accessFormat.Format(&record))
+}
@@ -0,0 +1,57 @@
+package log
+
+import (
+	"maps"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"moefile/pkg/logger"
+)
+
+func loadTagLevels(spec, file string) (logger.TagLevels, error) {
+	levels, err := logger.ParseTagLevels(spec)
+	if err != nil {
+		return nil, err
+	}
+	if file == "" {
+		return levels, nil
+	}
+
+	buf, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	fileLevels, err := logger.ParseTagLevels(string(buf))
+	if err != nil {
+		return nil, err
+	}
+	maps.Copy(levels, fileLevels)
+	return levels, nil
+}
+
+func SetupTagLevels(spec, file string) error {
+	levels, err := loadTagLevels(spec, file)
+	if err != nil {
+		return err
+	}
+	AppLogger.SetTagLevels(levels)
+	return nil
+}
+
+// WatchTagLevels reloads the tag levels when SIGHUP is received
+func WatchTagLevels(spec, file string) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	go func() {
+		for range ch {
+			levels, err := loadTagLevels(spec, file)
+			if err != nil {
+				T("log").Errf("Unable to reload tag levels, keeping the current ones: %v", err)
+				continue
+			}
+			AppLogger.SetTagLevels(levels)
+			T("log").Inff("Tag levels reloaded: %s", levels)
+		}
+	}()
+}
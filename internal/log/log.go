@@ -2,12 +2,20 @@ package log
 
 import (
 	"fmt"
-	"moefile/pkg/logger"
+	"log/slog"
+	"net/http"
+	"net/netip"
+	"time"
 
-	"github.com/gin-gonic/gin"
+	"moefile/pkg/httpx"
+	"moefile/pkg/logger"
+	"moefile/pkg/moefile"
 )
 
-var AppLogger = logger.NewStdout()
+var (
+	AppLogger        = logger.NewStdout()
+	structuredAccess = false
+)
 
 func T(name string) *logger.Tag {
 	return AppLogger.Tag(name)
@@ -16,40 +24,80 @@ func T(name string) *logger.Tag {
 func Setup(minLevel logger.LogLevel) {
 	AppLogger.MinLevel = minLevel
 	AppLogger.TagColor = map[string]logger.LogColor{
-		"gin":    logger.CMagenta,
 		"main":   logger.CYellow,
 		"http":   logger.CGreen,
 		"server": logger.CBlue,
 	}
 }
 
-func SetupGin1() {
-	gin.DebugPrintFunc = T("gin").Wrnf
-	gin.DefaultWriter = T("http").LogWriter(logger.LInf)
+func SetupFormat(formatter logger.Formatter) {
+	AppLogger.SetFormatter(formatter)
+	_, structuredAccess = formatter.(logger.JSONFormatter)
+	slog.SetDefault(slog.New(AppLogger.SlogHandler("slog")))
 }
 
-func SetupGin2(e *gin.Engine) {
-	e.Use(gin.LoggerWithFormatter(func(param gin.LogFormatterParams) string {
-		statusCode := fmt.Sprintf("%d", param.StatusCode)
-		statusColor := logger.CReset
-		if param.StatusCode >= 400 {
-			statusColor = logger.CRed
-		}
-		if param.StatusCode >= 300 && param.StatusCode < 400 {
-			statusColor = logger.CYellow
-		}
-		if param.StatusCode >= 200 && param.StatusCode < 300 {
-			statusColor = logger.CGreen
+func SetupAsync(queueSize int) {
+	if queueSize > 0 {
+		AppLogger.SetAsync(queueSize)
+	}
+}
+
+func Close() {
+	closeAccessLog()
+	//nolint:errcheck
+	AppLogger.Close()
+}
+
+// AccessLog wraps h to log each request, in the standard access log format when configured
+func AccessLog(h http.Handler, trustedProxies []netip.Prefix) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rw := httpx.NewResponseWriter(w)
+		h.ServeHTTP(rw, r)
+
+		clientIP := httpx.ClientIP(r, trustedProxies)
+		requestID := rw.Header().Get(moefile.HTTPHeaderRequestID)
+		switch {
+		case accessFormat != nil:
+			writeAccessRecord(rw, r, start, clientIP, requestID)
+		case structuredAccess:
+			T("http").Infw("request",
+				"client_ip", clientIP,
+				"status", rw.Status(),
+				"method", r.Method,
+				"path", r.URL.Path,
+				"latency", time.Since(start),
+				"ua", r.UserAgent(),
+				"request_id", requestID,
+			)
+		default:
+			T("http").Inff("%s", formatAccessLine(rw.Status(), r, start, clientIP, requestID))
 		}
-		coloredStatausCode := fmt.Sprint(statusColor, statusCode, logger.CReset)
-		return fmt.Sprint(
-			fmt.Sprintf("%-15s", param.ClientIP), " ",
-			coloredStatausCode, " ",
-			fmt.Sprintf("%-6s", param.Method), " ",
-			param.Path, " ",
-			"t=", param.Latency, " ",
-			"ua=", param.Request.UserAgent(), " ",
-			"msg=", param.ErrorMessage, "\n",
-		)
-	}))
+	})
+}
+
+func formatAccessLine(status int, r *http.Request, start time.Time, clientIP, requestID string) string {
+	statusColor := logger.CReset
+	if status >= 400 {
+		statusColor = logger.CRed
+	}
+	if status >= 300 && status < 400 {
+		statusColor = logger.CYellow
+	}
+	if status >= 200 && status < 300 {
+		statusColor = logger.CGreen
+	}
+	path := r.URL.Path
+	if r.URL.RawQuery != "" {
+		path = path + "?" + r.URL.RawQuery
+	}
+	return fmt.Sprint(
+		fmt.Sprintf("%-15s", clientIP), " ",
+		AppLogger.Colorize(statusColor, fmt.Sprint(status)), " ",
+		fmt.Sprintf("%-6s", r.Method), " ",
+		path, " ",
+		"t=", time.Since(start), " ",
+		"ua=", r.UserAgent(), " ",
+		"request_id=", requestID,
+	)
 }
@@ -0,0 +1,88 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"moefile/internal/meta"
+	"moefile/pkg/logger"
+)
+
+// ParseSinks parses specs like "stdout,file:/var/log/moefile.log,err:syslog".
+// Each item is a sink with an optional "<level>:" prefix as the minimal level written to it.
+func ParseSinks(spec string, rotate logger.RotateOptions) ([]logger.Route, error) {
+	routes := make([]logger.Route, 0)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		minLevel := logger.LDbg
+		if prefix, rest, ok := strings.Cut(item, ":"); ok {
+			if level, err := logger.ParseLevel(prefix); err == nil {
+				minLevel, item = level, rest
+			}
+		}
+
+		w, err := openSink(item, rotate)
+		if err != nil {
+			for _, r := range routes {
+				closeSink(r.Writer)
+			}
+			return nil, err
+		}
+		routes = append(routes, logger.Route{MinLevel: minLevel, Writer: w})
+	}
+	return routes, nil
+}
+
+func openSink(sink string, rotate logger.RotateOptions) (io.Writer, error) {
+	kind, arg, _ := strings.Cut(sink, ":")
+	switch kind {
+	case "stdout":
+		return os.Stdout, nil
+	case "stderr":
+		return os.Stderr, nil
+	case "file":
+		if arg == "" {
+			return nil, fmt.Errorf("log sink <%s>: file path is required", sink)
+		}
+		return logger.NewRotatingFile(arg, rotate)
+	case "syslog":
+		return logger.NewSyslog(arg, strings.ToLower(meta.AppName))
+	default:
+		return nil, fmt.Errorf("unknown log sink: %s", sink)
+	}
+}
+
+func closeSink(w io.Writer) {
+	if c, ok := w.(io.Closer); ok && w != os.Stdout && w != os.Stderr {
+		c.Close()
+	}
+}
+
+func SetupSinks(spec string, rotate logger.RotateOptions, color logger.ColorMode) error {
+	routes, err := ParseSinks(spec, rotate)
+	if err != nil {
+		return err
+	}
+
+	// an entry is formatted once for all sinks, so it is colored only when every sink should be
+	AppLogger.Color = len(routes) > 0
+	for _, r := range routes {
+		AppLogger.Color = AppLogger.Color && logger.ShouldColor(color, r.Writer)
+	}
+
+	switch {
+	case len(routes) == 0:
+		return fmt.Errorf("no log sink configured")
+	case len(routes) == 1 && routes[0].MinLevel == logger.LDbg:
+		AppLogger.Writer = routes[0].Writer
+	default:
+		AppLogger.Writer = logger.NewLevelRouter(routes...)
+	}
+	return nil
+}
@@ -0,0 +1,29 @@
+package meta
+
+import "time"
+
+var BuildTime = parseBuildTime()
+
+func parseBuildTime() time.Time {
+	t, err := time.Parse(time.RFC3339, BuildTimestamp)
+	if err == nil {
+		return t
+	}
+
+	t, err = time.Parse(time.RFC3339Nano, BuildTimestamp)
+	if err == nil {
+		return t
+	}
+
+	t, err = time.Parse("2006-01-02 15:04:05 -0700 MST", BuildTimestamp)
+	if err == nil {
+		return t
+	}
+
+	t, err = time.Parse("2006-01-02 15:04:05 -0700", BuildTimestamp)
+	if err == nil {
+		return t
+	}
+
+	return time.Now()
+}
@@ -0,0 +1,16 @@
+package metrics
+
+import (
+	"moefile/pkg/metrics"
+	"moefile/pkg/moefile"
+)
+
+var (
+	Registry = metrics.NewRegistry()
+	Server   = moefile.NewMetrics()
+)
+
+func init() {
+	Registry.Register(Server.Collectors()...)
+	Registry.Register(metrics.NewGoCollector())
+}
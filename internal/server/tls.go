@@ -0,0 +1,29 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// RedirectHandler redirects plain HTTP requests to HTTPS on the same host at port
+func RedirectHandler(port string) http.Handler {
+	if port == "443" {
+		port = ""
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host, _, err := net.SplitHostPort(r.Host)
+		if err != nil {
+			host = r.Host
+		}
+		if port != "" {
+			host = net.JoinHostPort(strings.Trim(host, "[]"), port)
+		}
+
+		code := http.StatusPermanentRedirect
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			code = http.StatusMovedPermanently
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), code)
+	})
+}
@@ -0,0 +1,62 @@
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"moefile/internal/log"
+	"moefile/internal/meta"
+	"moefile/pkg/trace"
+)
+
+var Tracer *trace.Tracer
+
+func Setup(exporter, endpoint string, sampleRatio float64) error {
+	var exp trace.Exporter
+	switch strings.ToLower(exporter) {
+	case "", "off":
+		return nil
+	case "stdout":
+		exp = trace.NewStdoutExporter(os.Stdout)
+	case "otlp":
+		if endpoint == "" {
+			endpoint = os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+		}
+		if endpoint == "" {
+			return fmt.Errorf("otlp endpoint is required")
+		}
+		exp = trace.NewOTLPExporter(endpoint, parseHeaders(os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")))
+	default:
+		return fmt.Errorf("unknown trace exporter: %s", exporter)
+	}
+
+	Tracer = trace.NewTracer(strings.ToLower(meta.AppName), exp)
+	Tracer.SampleRatio = sampleRatio
+	Tracer.OnError = func(err error) {
+		log.T("trace").Errf("Unable to export spans: %v", err)
+	}
+	return nil
+}
+
+func Shutdown(ctx context.Context) error {
+	return Tracer.Shutdown(ctx)
+}
+
+func Start(ctx context.Context, name string) (context.Context, *trace.Span) {
+	return Tracer.Start(ctx, name, trace.KindInternal)
+}
+
+// parseHeaders parses OTEL_EXPORTER_OTLP_HEADERS, e.g. "api-key=secret,tenant=moe"
+func parseHeaders(s string) map[string]string {
+	headers := map[string]string{}
+	for _, item := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(item, "=")
+		if !ok {
+			continue
+		}
+		headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return headers
+}
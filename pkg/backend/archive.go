@@ -0,0 +1,222 @@
+package backend
+
+import (
+	"io"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"time"
+)
+
+// archiveFS is an index of the entries of an archive, the parent directories missing in the archive
+// are created with the modification time of the archive
+type archiveFS struct {
+	kind    string
+	source  string
+	modTime time.Time
+	entries map[string]*archiveEntry
+	closer  io.Closer
+}
+
+type archiveEntry struct {
+	info     entryInfo
+	section  *io.SectionReader
+	open     func() (io.ReadCloser, error)
+	children []string
+}
+
+type entryInfo struct {
+	name    string
+	size    int64
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+func newArchiveFS(kind, source string, modTime time.Time, closer io.Closer) *archiveFS {
+	a := &archiveFS{
+		kind:    kind,
+		source:  source,
+		modTime: modTime,
+		entries: make(map[string]*archiveEntry),
+		closer:  closer,
+	}
+	a.entries["."] = &archiveEntry{info: entryInfo{name: ".", mode: fs.ModeDir | 0o555, modTime: modTime}}
+	return a
+}
+
+// cleanName returns false for names escaping the archive root
+func cleanName(name string) (string, bool) {
+	name = path.Clean(strings.TrimPrefix(strings.ReplaceAll(name, "\\", "/"), "/"))
+	if name == "." || !fs.ValidPath(name) {
+		return "", false
+	}
+	return name, true
+}
+
+func (a *archiveFS) addDir(name string, modTime time.Time) {
+	if e, ok := a.entries[name]; ok {
+		if e.info.IsDir() && !modTime.IsZero() {
+			e.info.modTime = modTime
+		}
+		return
+	}
+	a.addParents(name)
+	a.entries[name] = &archiveEntry{info: entryInfo{name: path.Base(name), mode: fs.ModeDir | 0o555, modTime: modTime}}
+}
+
+func (a *archiveFS) addFile(name string, size int64, modTime time.Time, section *io.SectionReader, open func() (io.ReadCloser, error)) {
+	if _, ok := a.entries[name]; ok {
+		return
+	}
+	a.addParents(name)
+	a.entries[name] = &archiveEntry{
+		info:    entryInfo{name: path.Base(name), size: size, mode: 0o444, modTime: modTime},
+		section: section,
+		open:    open,
+	}
+}
+
+func (a *archiveFS) addParents(name string) {
+	dir := path.Dir(name)
+	if dir == "." {
+		return
+	}
+	if _, ok := a.entries[dir]; !ok {
+		a.addDir(dir, time.Time{})
+	}
+}
+
+// index links the entries to their parents, it must be called after all entries are added
+func (a *archiveFS) index() {
+	for name, e := range a.entries {
+		if e.info.modTime.IsZero() {
+			e.info.modTime = a.modTime
+		}
+		if name == "." {
+			continue
+		}
+		parent := a.entries[path.Dir(name)]
+		parent.children = append(parent.children, name)
+	}
+	for _, e := range a.entries {
+		slices.Sort(e.children)
+	}
+}
+
+func (a *archiveFS) lookup(op, name string) (*archiveEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	e, ok := a.entries[name]
+	if !ok {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return e, nil
+}
+
+func (a *archiveFS) Open(name string) (fs.File, error) {
+	e, err := a.lookup("open", name)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case e.info.IsDir():
+		return &archiveDir{fs: a, entry: e}, nil
+	case e.section != nil:
+		return &sectionFile{SectionReader: io.NewSectionReader(e.section, 0, e.info.size), info: e.info}, nil
+	}
+	return newSeekFile(e.info, nil, e.open), nil
+}
+
+func (a *archiveFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	e, err := a.lookup("readdir", name)
+	if err != nil {
+		return nil, err
+	}
+	if !e.info.IsDir() {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	entries := make([]fs.DirEntry, 0, len(e.children))
+	for _, child := range e.children {
+		entries = append(entries, fs.FileInfoToDirEntry(a.entries[child].info))
+	}
+	return entries, nil
+}
+
+func (a *archiveFS) Stat(name string) (fs.FileInfo, error) {
+	e, err := a.lookup("stat", name)
+	if err != nil {
+		return nil, err
+	}
+	return e.info, nil
+}
+
+func (a *archiveFS) Close() error {
+	if a.closer == nil {
+		return nil
+	}
+	return a.closer.Close()
+}
+
+func (a *archiveFS) Kind() string {
+	return a.kind
+}
+
+func (a *archiveFS) Source() string {
+	return a.source
+}
+
+type sectionFile struct {
+	*io.SectionReader
+	info entryInfo
+}
+
+func (f *sectionFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *sectionFile) Close() error {
+	return nil
+}
+
+type archiveDir struct {
+	fs     *archiveFS
+	entry  *archiveEntry
+	offset int
+}
+
+func (d *archiveDir) Stat() (fs.FileInfo, error) {
+	return d.entry.info, nil
+}
+
+func (d *archiveDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.entry.info.name, Err: fs.ErrInvalid}
+}
+
+func (d *archiveDir) Close() error {
+	return nil
+}
+
+func (d *archiveDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entry.children[d.offset:]
+	if n > 0 && len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if n > 0 && n < len(rest) {
+		rest = rest[:n]
+	}
+	d.offset += len(rest)
+	entries := make([]fs.DirEntry, 0, len(rest))
+	for _, child := range rest {
+		entries = append(entries, fs.FileInfoToDirEntry(d.fs.entries[child].info))
+	}
+	return entries, nil
+}
+
+func (i entryInfo) Name() string       { return i.name }
+func (i entryInfo) Size() int64        { return i.size }
+func (i entryInfo) Mode() fs.FileMode  { return i.mode }
+func (i entryInfo) ModTime() time.Time { return i.modTime }
+func (i entryInfo) IsDir() bool        { return i.mode.IsDir() }
+func (i entryInfo) Sys() any           { return nil }
@@ -0,0 +1,160 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	KindDir     = "dir"
+	KindZip     = "zip"
+	KindTar     = "tar"
+	KindFS      = "fs"
+	KindOverlay = "overlay"
+)
+
+var ErrUnsupported = errors.New("backend: unsupported source")
+
+// Backend is a read-only file system, the regular files opened from it are always io.Seeker
+// so that Range requests work the same on every backend
+type Backend interface {
+	fs.ReadDirFS
+	fs.StatFS
+	io.Closer
+	Kind() string
+	Source() string
+}
+
+type wrapFS struct {
+	fsys   fs.FS
+	kind   string
+	source string
+}
+
+// Wrap makes a Backend of any fs.FS, such as fstest.MapFS
+func Wrap(kind, source string, fsys fs.FS) Backend {
+	if b, ok := fsys.(Backend); ok {
+		return b
+	}
+	return &wrapFS{fsys: fsys, kind: kind, source: source}
+}
+
+func Dir(path string) (Backend, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("%w: not a directory: %s", ErrUnsupported, abs)
+	}
+	return Wrap(KindDir, abs, os.DirFS(abs)), nil
+}
+
+// Open opens a backend by spec, which is a directory, a ZIP or tar archive, optionally prefixed by its kind
+// like zip:/srv/a.bin. Several specs are merged as a union by the precedence.
+func Open(specs []string, precedence Precedence) (Backend, error) {
+	if len(specs) == 0 {
+		return openSpec("")
+	}
+	if len(specs) == 1 {
+		return openSpec(specs[0])
+	}
+
+	layers := make([]Backend, 0, len(specs))
+	for _, spec := range specs {
+		layer, err := openSpec(spec)
+		if err != nil {
+			for _, l := range layers {
+				//nolint:errcheck
+				l.Close()
+			}
+			return nil, err
+		}
+		layers = append(layers, layer)
+	}
+	return Union(precedence, layers...), nil
+}
+
+func openSpec(spec string) (Backend, error) {
+	kind, path := detect(spec)
+	switch kind {
+	case KindDir:
+		return Dir(path)
+	case KindZip:
+		return OpenZip(path)
+	case KindTar:
+		return OpenTar(path)
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupported, spec)
+}
+
+// detect guesses the kind by the extension, unless it is prefixed or the path is a directory
+func detect(spec string) (kind, path string) {
+	for _, k := range []string{KindDir, KindZip, KindTar} {
+		if p, ok := strings.CutPrefix(spec, k+":"); ok {
+			return k, p
+		}
+	}
+
+	if spec == "" {
+		spec = "."
+	}
+	if info, err := os.Stat(spec); err == nil && info.IsDir() {
+		return KindDir, spec
+	}
+	name := strings.ToLower(spec)
+	switch {
+	case strings.HasSuffix(name, ".zip"):
+		return KindZip, spec
+	case strings.HasSuffix(name, ".tar"), strings.HasSuffix(name, ".tar.gz"), strings.HasSuffix(name, ".tgz"):
+		return KindTar, spec
+	}
+	return KindDir, spec
+}
+
+func (w *wrapFS) Open(name string) (fs.File, error) {
+	f, err := w.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := f.(io.Seeker); ok {
+		return f, nil
+	}
+	info, err := f.Stat()
+	if err != nil || !info.Mode().IsRegular() {
+		return f, nil
+	}
+	return newSeekFile(info, f, func() (io.ReadCloser, error) { return w.fsys.Open(name) }), nil
+}
+
+func (w *wrapFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return fs.ReadDir(w.fsys, name)
+}
+
+func (w *wrapFS) Stat(name string) (fs.FileInfo, error) {
+	return fs.Stat(w.fsys, name)
+}
+
+func (w *wrapFS) Close() error {
+	if c, ok := w.fsys.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
+
+func (w *wrapFS) Kind() string {
+	return w.kind
+}
+
+func (w *wrapFS) Source() string {
+	return w.source
+}
@@ -0,0 +1,254 @@
+package backend
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"slices"
+	"strings"
+	"syscall"
+)
+
+// Precedence decides which layer of a union provides a name found in several layers,
+// directories are always merged
+type Precedence int
+
+const (
+	PrecedenceFirst Precedence = iota
+	PrecedenceLast
+	PrecedenceNewest
+	PrecedenceLargest
+)
+
+var precedenceNames = []string{"first", "last", "newest", "largest"}
+
+func (p Precedence) String() string {
+	if int(p) < len(precedenceNames) {
+		return precedenceNames[p]
+	}
+	return fmt.Sprintf("Precedence(%d)", int(p))
+}
+
+func ParsePrecedence(s string) (Precedence, error) {
+	i := slices.Index(precedenceNames, strings.ToLower(strings.TrimSpace(s)))
+	if i < 0 {
+		return PrecedenceFirst, fmt.Errorf("unknown precedence: %s", s)
+	}
+	return Precedence(i), nil
+}
+
+type overlayFS struct {
+	layers     []Backend
+	precedence Precedence
+}
+
+// Overlay merges the layers, the files in the upper layers hide the ones with the same name in the lower layers
+func Overlay(layers ...Backend) Backend {
+	return Union(PrecedenceFirst, layers...)
+}
+
+// Union merges the layers into one tree, the colliding names are resolved by the precedence
+func Union(precedence Precedence, layers ...Backend) Backend {
+	return &overlayFS{layers: layers, precedence: precedence}
+}
+
+// ordered returns the layers in the order they are looked up
+func (o *overlayFS) ordered() []Backend {
+	if o.precedence != PrecedenceLast {
+		return o.layers
+	}
+	layers := slices.Clone(o.layers)
+	slices.Reverse(layers)
+	return layers
+}
+
+// notInLayer reports whether err means the name is missing in a layer, such as when a parent is a file there
+func notInLayer(err error) bool {
+	return errors.Is(err, fs.ErrNotExist) || errors.Is(err, syscall.ENOTDIR)
+}
+
+// prefer reports whether b wins over a, which is found in an earlier layer
+func (o *overlayFS) prefer(a, b fs.FileInfo) bool {
+	switch o.precedence {
+	case PrecedenceNewest:
+		return b.ModTime().After(a.ModTime())
+	case PrecedenceLargest:
+		return b.Size() > a.Size()
+	}
+	return false
+}
+
+func (o *overlayFS) resolve(op, name string) (Backend, fs.FileInfo, error) {
+	var layer Backend
+	var info fs.FileInfo
+	for _, l := range o.ordered() {
+		i, err := l.Stat(name)
+		if notInLayer(err) {
+			continue
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if info == nil || o.prefer(info, i) {
+			layer, info = l, i
+		}
+		if o.precedence == PrecedenceFirst || o.precedence == PrecedenceLast {
+			break
+		}
+	}
+	if info == nil {
+		return nil, nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return layer, withSource(info, layer), nil
+}
+
+func (o *overlayFS) Open(name string) (fs.File, error) {
+	layer, info, err := o.resolve("open", name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := layer.Open(name)
+	if err != nil || !info.IsDir() {
+		return f, err
+	}
+	return &overlayDir{File: f, fs: o, name: name}, nil
+}
+
+func (o *overlayFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	found := false
+	index := make(map[string]int)
+	entries := make([]fs.DirEntry, 0)
+	for _, layer := range o.ordered() {
+		// a file of the same name in a layer does not hide the directories of the others
+		info, err := layer.Stat(name)
+		if notInLayer(err) || (err == nil && !info.IsDir()) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		layerEntries, err := layer.ReadDir(name)
+		if err != nil {
+			return nil, err
+		}
+		found = true
+		for _, entry := range layerEntries {
+			entry = &sourceEntry{DirEntry: entry, layer: layer}
+			i, ok := index[entry.Name()]
+			if !ok {
+				index[entry.Name()] = len(entries)
+				entries = append(entries, entry)
+				continue
+			}
+			if o.precedence != PrecedenceNewest && o.precedence != PrecedenceLargest {
+				continue
+			}
+			a, errA := entries[i].Info()
+			b, errB := entry.Info()
+			if errA == nil && errB == nil && o.prefer(a, b) {
+				entries[i] = entry
+			}
+		}
+	}
+	if !found {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	slices.SortFunc(entries, func(a, b fs.DirEntry) int { return strings.Compare(a.Name(), b.Name()) })
+	return entries, nil
+}
+
+func (o *overlayFS) Stat(name string) (fs.FileInfo, error) {
+	_, info, err := o.resolve("stat", name)
+	return info, err
+}
+
+func (o *overlayFS) Close() error {
+	errs := make([]error, 0)
+	for _, layer := range o.layers {
+		errs = append(errs, layer.Close())
+	}
+	return errors.Join(errs...)
+}
+
+func (o *overlayFS) Kind() string {
+	return KindOverlay
+}
+
+func (o *overlayFS) Source() string {
+	sources := make([]string, 0, len(o.layers))
+	for _, layer := range o.layers {
+		sources = append(sources, layer.Kind()+":"+layer.Source())
+	}
+	return strings.Join(sources, ",")
+}
+
+func (o *overlayFS) Layers() []Backend {
+	return o.layers
+}
+
+func (o *overlayFS) Precedence() Precedence {
+	return o.precedence
+}
+
+// EntrySource returns the source of the layer which provides a file of a union, or "" for other backends
+func EntrySource(info fs.FileInfo) string {
+	if s, ok := info.(*sourceInfo); ok {
+		return s.source
+	}
+	return ""
+}
+
+// sourceInfo keeps the source of the innermost layer when unions are nested
+type sourceInfo struct {
+	fs.FileInfo
+	source string
+}
+
+func withSource(info fs.FileInfo, layer Backend) fs.FileInfo {
+	if _, ok := info.(*sourceInfo); ok {
+		return info
+	}
+	return &sourceInfo{FileInfo: info, source: layer.Source()}
+}
+
+type sourceEntry struct {
+	fs.DirEntry
+	layer Backend
+}
+
+func (e *sourceEntry) Info() (fs.FileInfo, error) {
+	info, err := e.DirEntry.Info()
+	if err != nil {
+		return nil, err
+	}
+	return withSource(info, e.layer), nil
+}
+
+// overlayDir lists the merged entries of all layers
+type overlayDir struct {
+	fs.File
+	fs      *overlayFS
+	name    string
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *overlayDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if d.entries == nil {
+		entries, err := d.fs.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+		d.entries = entries
+	}
+	rest := d.entries[d.offset:]
+	if n > 0 && len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if n > 0 && n < len(rest) {
+		rest = rest[:n]
+	}
+	d.offset += len(rest)
+	return rest, nil
+}
@@ -0,0 +1,86 @@
+package backend
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+)
+
+var errNegativeOffset = errors.New("backend: negative offset")
+
+// seekFile makes a stream seekable by skipping forward, or reopening it to seek backward,
+// the stream is opened lazily so that seeking to the end to get the size costs nothing
+type seekFile struct {
+	info fs.FileInfo
+	open func() (io.ReadCloser, error)
+	r    io.ReadCloser
+	pos  int64
+	rpos int64
+}
+
+func newSeekFile(info fs.FileInfo, r io.ReadCloser, open func() (io.ReadCloser, error)) *seekFile {
+	return &seekFile{info: info, open: open, r: r}
+}
+
+func (f *seekFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *seekFile) Read(p []byte) (int, error) {
+	if f.pos >= f.info.Size() {
+		return 0, io.EOF
+	}
+	if f.r == nil || f.rpos > f.pos {
+		err := f.reopen()
+		if err != nil {
+			return 0, err
+		}
+	}
+	if f.rpos < f.pos {
+		n, err := io.CopyN(io.Discard, f.r, f.pos-f.rpos)
+		f.rpos += n
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.r.Read(p)
+	f.pos += int64(n)
+	f.rpos += int64(n)
+	return n, err
+}
+
+func (f *seekFile) reopen() error {
+	if f.r != nil {
+		//nolint:errcheck
+		f.r.Close()
+		f.r = nil
+	}
+	r, err := f.open()
+	if err != nil {
+		return err
+	}
+	f.r, f.rpos = r, 0
+	return nil
+}
+
+func (f *seekFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.pos
+	case io.SeekEnd:
+		offset += f.info.Size()
+	}
+	if offset < 0 {
+		return f.pos, errNegativeOffset
+	}
+	f.pos = offset
+	return offset, nil
+}
+
+func (f *seekFile) Close() error {
+	if f.r == nil {
+		return nil
+	}
+	return f.r.Close()
+}
@@ -0,0 +1,139 @@
+package backend
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+var gzipMagic = []byte{0x1f, 0x8b}
+
+// OpenTar opens a read-only tar archive, which may be compressed by gzip. The entries of a plain tar
+// are read in place, and the entries of a compressed tar are read by decompressing the archive from the start
+func OpenTar(path string) (Backend, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(abs)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		//nolint:errcheck
+		f.Close()
+		return nil, err
+	}
+
+	compressed, err := isGzip(f)
+	if err != nil {
+		//nolint:errcheck
+		f.Close()
+		return nil, err
+	}
+	a := newArchiveFS(KindTar, abs, info.ModTime(), f)
+	err = indexTar(a, f, compressed)
+	if err != nil {
+		//nolint:errcheck
+		f.Close()
+		return nil, err
+	}
+	a.index()
+	return a, nil
+}
+
+func isGzip(f *os.File) (bool, error) {
+	magic := make([]byte, len(gzipMagic))
+	n, err := f.ReadAt(magic, 0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return false, err
+	}
+	return n == len(magic) && magic[0] == gzipMagic[0] && magic[1] == gzipMagic[1], nil
+}
+
+func newTarReader(f *os.File, compressed bool) (*tar.Reader, io.Closer, error) {
+	r := io.NewSectionReader(f, 0, 1<<63-1)
+	if !compressed {
+		return tar.NewReader(r), io.NopCloser(nil), nil
+	}
+	gz, err := gzip.NewReader(bufio.NewReader(r))
+	if err != nil {
+		return nil, nil, err
+	}
+	return tar.NewReader(gz), gz, nil
+}
+
+func indexTar(a *archiveFS, f *os.File, compressed bool) error {
+	section := io.NewSectionReader(f, 0, 1<<63-1)
+	var tr *tar.Reader
+	if compressed {
+		var err error
+		tr, _, err = newTarReader(f, true)
+		if err != nil {
+			return err
+		}
+	} else {
+		// archive/tar does not read ahead, so the offset after Next is where the data starts
+		tr = tar.NewReader(section)
+	}
+
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		name, ok := cleanName(hdr.Name)
+		if !ok {
+			continue
+		}
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			a.addDir(name, hdr.ModTime)
+		case tar.TypeReg:
+			if !compressed {
+				offset, err := section.Seek(0, io.SeekCurrent)
+				if err != nil {
+					return err
+				}
+				a.addFile(name, hdr.Size, hdr.ModTime, io.NewSectionReader(f, offset, hdr.Size), nil)
+				continue
+			}
+			a.addFile(name, hdr.Size, hdr.ModTime, nil, openTarEntry(f, hdr.Name))
+		}
+	}
+}
+
+func openTarEntry(f *os.File, name string) func() (io.ReadCloser, error) {
+	return func() (io.ReadCloser, error) {
+		tr, closer, err := newTarReader(f, true)
+		if err != nil {
+			return nil, err
+		}
+		for {
+			hdr, err := tr.Next()
+			if err != nil {
+				//nolint:errcheck
+				closer.Close()
+				if errors.Is(err, io.EOF) {
+					err = io.ErrUnexpectedEOF
+				}
+				return nil, err
+			}
+			if hdr.Name == name {
+				return struct {
+					io.Reader
+					io.Closer
+				}{tr, closer}, nil
+			}
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package backend
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// OpenZip opens a read-only ZIP archive, the stored entries are read in place and the compressed
+// entries are decompressed from the start when seeking backward
+func OpenZip(path string) (Backend, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(abs)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		//nolint:errcheck
+		f.Close()
+		return nil, err
+	}
+	r, err := zip.NewReader(f, info.Size())
+	if err != nil {
+		//nolint:errcheck
+		f.Close()
+		return nil, err
+	}
+
+	a := newArchiveFS(KindZip, abs, info.ModTime(), f)
+	for _, zf := range r.File {
+		name, ok := cleanName(zf.Name)
+		if !ok {
+			continue
+		}
+		if strings.HasSuffix(zf.Name, "/") || zf.FileInfo().IsDir() {
+			a.addDir(name, zf.Modified)
+			continue
+		}
+		if !zf.Mode().IsRegular() {
+			continue
+		}
+
+		var section *io.SectionReader
+		if zf.Method == zip.Store {
+			if offset, err := zf.DataOffset(); err == nil {
+				section = io.NewSectionReader(f, offset, int64(zf.UncompressedSize64))
+			}
+		}
+		a.addFile(name, int64(zf.UncompressedSize64), zf.Modified, section, zf.Open)
+	}
+	a.index()
+	return a, nil
+}
@@ -0,0 +1,146 @@
+package compress
+
+import (
+	"bytes"
+	"io"
+	"mime"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+const (
+	EncIdentity = "identity"
+	EncGzip     = "gzip"
+	EncBrotli   = "br"
+	EncZstd     = "zstd"
+
+	MinSize = 1024
+)
+
+var (
+	// Server side preference when the client accepts several encodings with the same q-value
+	Preference = []string{EncBrotli, EncZstd, EncGzip}
+
+	SidecarExt = map[string]string{
+		EncBrotli: ".br",
+		EncZstd:   ".zst",
+		EncGzip:   ".gz",
+	}
+
+	CompressibleTypes = []string{
+		"application/javascript",
+		"application/json",
+		"application/x-ndjson",
+		"application/xml",
+		"application/xhtml+xml",
+		"image/svg+xml",
+		"application/wasm",
+	}
+)
+
+func IsCompressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if strings.HasPrefix(mediaType, "text/") {
+		return true
+	}
+	return slices.Contains(CompressibleTypes, mediaType)
+}
+
+func IsCompressibleName(name string) bool {
+	return IsCompressible(mime.TypeByExtension(path.Ext(name)))
+}
+
+func Negotiate(acceptEncoding string, available ...string) string {
+	best, bestQ := EncIdentity, 0.0
+	for _, enc := range Preference {
+		if !slices.Contains(available, enc) {
+			continue
+		}
+		q := acceptQuality(acceptEncoding, enc)
+		if q > bestQ {
+			best, bestQ = enc, q
+		}
+	}
+	return best
+}
+
+func acceptQuality(acceptEncoding, enc string) float64 {
+	wildcard := 0.0
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		if name == enc {
+			return q
+		}
+		if name == "*" {
+			wildcard = q
+		}
+	}
+	return wildcard
+}
+
+func NewWriter(enc string, w io.Writer) (io.WriteCloser, error) {
+	switch enc {
+	case EncGzip:
+		return gzip.NewWriterLevel(w, gzip.BestCompression)
+	case EncBrotli:
+		return brotli.NewWriterLevel(w, brotli.BestCompression), nil
+	case EncZstd:
+		return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.SpeedBestCompression))
+	default:
+		return nopCloser{w}, nil
+	}
+}
+
+func NewFastWriter(enc string, w io.Writer) (io.WriteCloser, error) {
+	switch enc {
+	case EncGzip:
+		return gzip.NewWriterLevel(w, gzip.DefaultCompression)
+	case EncBrotli:
+		return brotli.NewWriterLevel(w, brotli.DefaultCompression), nil
+	case EncZstd:
+		return zstd.NewWriter(w, zstd.WithEncoderLevel(zstd.SpeedDefault), zstd.WithEncoderConcurrency(1))
+	default:
+		return nopCloser{w}, nil
+	}
+}
+
+func Encode(enc string, data []byte) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	w, err := NewFastWriter(enc, buf)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(data); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,22 @@
+package diskstat
+
+import "errors"
+
+var ErrUnsupported = errors.New("diskstat: unsupported platform")
+
+type Usage struct {
+	Total uint64
+	Free  uint64
+	Avail uint64
+}
+
+func (u Usage) Used() uint64 {
+	return u.Total - u.Free
+}
+
+func (u Usage) UsedPercent() float64 {
+	if u.Total == 0 {
+		return 0
+	}
+	return float64(u.Used()) / float64(u.Total) * 100
+}
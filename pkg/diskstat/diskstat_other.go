@@ -0,0 +1,7 @@
+//go:build !linux && !darwin && !freebsd && !windows
+
+package diskstat
+
+func Get(string) (Usage, error) {
+	return Usage{}, ErrUnsupported
+}
@@ -0,0 +1,18 @@
+//go:build linux || darwin || freebsd
+
+package diskstat
+
+import "syscall"
+
+func Get(path string) (Usage, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return Usage{}, err
+	}
+	bsize := uint64(st.Bsize)
+	return Usage{
+		Total: uint64(st.Blocks) * bsize,
+		Free:  uint64(st.Bfree) * bsize,
+		Avail: uint64(st.Bavail) * bsize,
+	}, nil
+}
@@ -0,0 +1,17 @@
+//go:build windows
+
+package diskstat
+
+import "golang.org/x/sys/windows"
+
+func Get(path string) (Usage, error) {
+	p, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return Usage{}, err
+	}
+	var avail, total, free uint64
+	if err = windows.GetDiskFreeSpaceEx(p, &avail, &total, &free); err != nil {
+		return Usage{}, err
+	}
+	return Usage{Total: total, Free: free, Avail: avail}, nil
+}
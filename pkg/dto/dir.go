@@ -16,28 +16,31 @@ var DefaultOwner = OwnerInfo{
 	DisplayName: "root",
 }
 
+// The JSON field names are published in res/schema.json, keep them stable
 type DirInfo struct {
-	BucketName  string     `xml:"Name"`
-	Path        string     `xml:"Prefix"`
-	IsTruncated bool       `xml:"IsTruncated"`
-	Files       []FileInfo `xml:"Contents"`
+	BucketName  string     `xml:"Name" json:"name"`
+	Path        string     `xml:"Prefix" json:"prefix"`
+	BasePath    string     `xml:"BasePath" json:"base_path"`
+	IsTruncated bool       `xml:"IsTruncated" json:"is_truncated"`
+	Files       []FileInfo `xml:"Contents" json:"contents"`
 }
 
 type FileInfo struct {
-	FileName         string    `xml:"FileName"`
-	IsDirectory      bool      `xml:"IsDirectory"`
-	FullPath         string    `xml:"Key"`
-	LastModified     string    `xml:"LastModified"`
-	LastModifiedUnix int64     `xml:"LastModifiedUnix"`
-	Hash             string    `xml:"ETag"`
-	Size             uint64    `xml:"Size"`
-	StorageClass     string    `xml:"StorageClass"`
-	Owner            OwnerInfo `xml:"Owner"`
+	FileName         string    `xml:"FileName" json:"file_name"`
+	IsDirectory      bool      `xml:"IsDirectory" json:"is_directory"`
+	FullPath         string    `xml:"Key" json:"key"`
+	LastModified     string    `xml:"LastModified" json:"last_modified"`
+	LastModifiedUnix int64     `xml:"LastModifiedUnix" json:"last_modified_unix"`
+	Hash             string    `xml:"ETag" json:"etag"`
+	Size             uint64    `xml:"Size" json:"size"`
+	StorageClass     string    `xml:"StorageClass" json:"storage_class"`
+	Owner            OwnerInfo `xml:"Owner" json:"owner"`
+	Source           string    `xml:"Source,omitempty" json:"source,omitempty"`
 }
 
 type OwnerInfo struct {
-	ID          string `xml:"ID"`
-	DisplayName string `xml:"DisplayName"`
+	ID          string `xml:"ID" json:"id"`
+	DisplayName string `xml:"DisplayName" json:"display_name"`
 }
 
 func NewFSDirInfo(serverName, path string) DirInfo {
@@ -73,6 +76,16 @@ func (i *DirInfo) AddFSFile(f fs.FileInfo) {
 	i.Files = append(i.Files, file)
 }
 
+func (i *DirInfo) Hash() string {
+	buf := []byte(i.BucketName + " " + i.BasePath + " " + i.Path)
+	for _, f := range i.Files {
+		buf = append(buf, ' ')
+		buf = append(buf, f.Hash...)
+		buf = append(buf, f.Source...)
+	}
+	return FastHash(buf)
+}
+
 func FastHash(v []byte) string {
 	hi := uint64(0x66ccff9920120712)
 	lo := uint64(0x114514190d000721)
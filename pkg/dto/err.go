@@ -1,5 +1,6 @@
 package dto
 
 type ErrorResponse struct {
-	Message string `xml:"Error"`
+	Message   string `xml:"Error"`
+	RequestID string `xml:"RequestId,omitempty"`
 }
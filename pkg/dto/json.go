@@ -0,0 +1,47 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type jsonListBucketResult struct {
+	Schema string `json:"$schema"`
+	ListBucketResult
+}
+
+// ToJSON marshals the listing with the same fields as the XML, and a link to the schema
+func (i *DirInfo) ToJSON(indent bool, schema string) ([]byte, error) {
+	result := jsonListBucketResult{
+		Schema: schema,
+		ListBucketResult: ListBucketResult{
+			DirInfo:  *i,
+			ServerTZ: time.Now().Format("-07:00"),
+		},
+	}
+
+	if indent {
+		return json.MarshalIndent(result, "", "\t")
+	}
+	return json.Marshal(result)
+}
+
+// NDJSONEntry is a line of the NDJSON listing, the long listing has a FileInfo per line instead
+type NDJSONEntry struct {
+	FileName    string `json:"file_name"`
+	IsDirectory bool   `json:"is_directory"`
+	FullPath    string `json:"key"`
+}
+
+// ToNDJSON marshals the entry as a line, with all fields of FileInfo when long is set
+func (f *FileInfo) ToNDJSON(long bool) ([]byte, error) {
+	var v any = NDJSONEntry{FileName: f.FileName, IsDirectory: f.IsDirectory, FullPath: f.FullPath}
+	if long {
+		v = f
+	}
+	buf, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	return append(buf, '\n'), nil
+}
@@ -3,13 +3,39 @@ package dto
 import "github.com/baobao1270/slang"
 
 type PlayerData struct {
+	BasePath   string      `json:"base_path"`
 	DanmakuURL string      `json:"danmaku"`
 	Subs       []PlayerSub `json:"subtitles"`
 }
 
 type PlayerSub struct {
-	Lang     string     `json:"lang"`
-	LangName string     `json:"lang_name"`
-	LangInfo slang.Lang `json:"lang_info"`
-	URL      string     `json:"url"`
+	Lang     string `json:"lang"`
+	LangName string `json:"lang_name"`
+	LangInfo Lang   `json:"lang_info"`
+	URL      string `json:"url"`
+}
+
+// Lang is the published form of slang.Lang, so that the schema does not follow the field names of the library
+type Lang struct {
+	Name       string `json:"name"`
+	Location   string `json:"location"`
+	MSLCID     uint32 `json:"ms_lcid"`
+	BCP47      string `json:"bcp47"`
+	WinID      string `json:"win_id"`
+	ISO639Set1 string `json:"iso639_1"`
+	ISO639Set2 string `json:"iso639_2"`
+	ISO639Set3 string `json:"iso639_3"`
+}
+
+func NewLang(l slang.Lang) Lang {
+	return Lang{
+		Name:       l.Name,
+		Location:   l.Location,
+		MSLCID:     l.MSLCID,
+		BCP47:      l.BCP47,
+		WinID:      l.WinID,
+		ISO639Set1: l.ISO639Set1,
+		ISO639Set2: l.ISO639Set2,
+		ISO639Set3: l.ISO639Set3,
+	}
 }
@@ -12,8 +12,8 @@ import (
 
 type ListBucketResult struct {
 	DirInfo
-	ServerTZ string `xml:"ServerTimezoneOffset"`
-	XSLT     string `xml:",innerxml"`
+	ServerTZ string `xml:"ServerTimezoneOffset" json:"server_timezone_offset"`
+	XSLT     string `xml:",innerxml" json:"-"`
 }
 
 func (i *DirInfo) ToS3XMLWithXSLT(indent bool, xslt string) (data []byte, err error) {
@@ -0,0 +1,35 @@
+package dto
+
+type StatusInfo struct {
+	AppName         string      `json:"app_name"`
+	Version         string      `json:"version"`
+	BuildTime       string      `json:"build_time"`
+	BuildMode       string      `json:"build_mode"`
+	ServerName      string      `json:"server_name"`
+	GoVersion       string      `json:"go_version"`
+	StartedAt       string      `json:"started_at"`
+	UptimeSeconds   int64       `json:"uptime_seconds"`
+	Uptime          string      `json:"uptime"`
+	OpenConnections int64       `json:"open_connections"`
+	Disk            *DiskInfo   `json:"disk,omitempty"`
+	Mounts          []MountInfo `json:"mounts"`
+}
+
+type DiskInfo struct {
+	Path        string  `json:"path"`
+	Total       uint64  `json:"total"`
+	Free        uint64  `json:"free"`
+	Used        uint64  `json:"used"`
+	UsedPercent float64 `json:"used_percent"`
+}
+
+type MountInfo struct {
+	Path    string `json:"path"`
+	Source  string `json:"source"`
+	Backend string `json:"backend"`
+}
+
+type ReadyInfo struct {
+	Ready  bool              `json:"ready"`
+	Checks map[string]string `json:"checks"`
+}
@@ -0,0 +1,161 @@
+// Package httpx has the small net/http helpers shared by the handler and the access loggers.
+package httpx
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// ResponseWriter records the status code and the number of body bytes written
+type ResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	size        int64
+	wroteHeader bool
+}
+
+// NewResponseWriter wraps w, or returns it as is when it is already a ResponseWriter
+func NewResponseWriter(w http.ResponseWriter) *ResponseWriter {
+	if rw, ok := w.(*ResponseWriter); ok {
+		return rw
+	}
+	return &ResponseWriter{ResponseWriter: w}
+}
+
+func (w *ResponseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	// 1xx informational responses are followed by the final one
+	if code >= http.StatusOK || code == http.StatusSwitchingProtocols {
+		w.status = code
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *ResponseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+// ReadFrom keeps sendfile working for http.ServeContent
+func (w *ResponseWriter) ReadFrom(r io.Reader) (int64, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	var n int64
+	var err error
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(r)
+	} else {
+		n, err = io.Copy(struct{ io.Writer }{w.ResponseWriter}, r)
+	}
+	w.size += n
+	return n, err
+}
+
+func (w *ResponseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if !w.wroteHeader {
+			w.WriteHeader(http.StatusOK)
+		}
+		f.Flush()
+	}
+}
+
+// Unwrap is used by http.ResponseController
+func (w *ResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Status returns 200 when nothing has been written yet, as net/http does
+func (w *ResponseWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+func (w *ResponseWriter) Size() int64 {
+	return w.size
+}
+
+func (w *ResponseWriter) Written() bool {
+	return w.wroteHeader
+}
+
+// RemoteIP returns the IP of the peer, or "" for unix sockets
+func RemoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(strings.TrimSpace(r.RemoteAddr))
+	if err != nil {
+		return ""
+	}
+	return host
+}
+
+// IsTrusted reports whether ip is in one of the prefixes
+func IsTrusted(ip string, prefixes []netip.Prefix) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsUnixSocket reports whether r is received on a unix socket
+func IsUnixSocket(r *http.Request) bool {
+	addr, ok := r.Context().Value(http.LocalAddrContextKey).(net.Addr)
+	return ok && addr.Network() == "unix"
+}
+
+// IsTrustedPeer reports whether the peer of r is a trusted proxy.
+// Peers on unix sockets are trusted, as only the local processes allowed by the socket permissions can connect.
+func IsTrustedPeer(r *http.Request, prefixes []netip.Prefix) bool {
+	return IsUnixSocket(r) || IsTrusted(RemoteIP(r), prefixes)
+}
+
+// ClientIP returns the client IP from X-Forwarded-For or X-Real-IP when the peer is a trusted proxy.
+// The addresses are read from right to left, and the first one not trusted is the client.
+func ClientIP(r *http.Request, trusted []netip.Prefix) string {
+	remoteIP := RemoteIP(r)
+	if !IsTrustedPeer(r, trusted) {
+		return remoteIP
+	}
+	for _, name := range []string{"X-Forwarded-For", "X-Real-IP"} {
+		if ip, ok := forwardedIP(r.Header.Get(name), trusted); ok {
+			return ip
+		}
+	}
+	return remoteIP
+}
+
+func forwardedIP(header string, trusted []netip.Prefix) (string, bool) {
+	if header == "" {
+		return "", false
+	}
+	items := strings.Split(header, ",")
+	for i := len(items) - 1; i >= 0; i-- {
+		ip := strings.TrimSpace(items[i])
+		if _, err := netip.ParseAddr(ip); err != nil {
+			return "", false
+		}
+		if i == 0 || !IsTrusted(ip, trusted) {
+			return ip, true
+		}
+	}
+	return "", false
+}
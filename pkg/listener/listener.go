@@ -0,0 +1,144 @@
+package listener
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+const (
+	PrefixUnix    = "unix:"
+	PrefixSystemd = "systemd"
+
+	DefaultSocketMode fs.FileMode = 0o660
+)
+
+type Addr struct {
+	Network string
+	Address string
+}
+
+func (a Addr) String() string {
+	switch a.Network {
+	case "unix":
+		return PrefixUnix + a.Address
+	case PrefixSystemd:
+		if a.Address == "" {
+			return PrefixSystemd
+		}
+		return PrefixSystemd + ":" + a.Address
+	}
+	return a.Address
+}
+
+// URL returns the address for display, such as http://127.0.0.1:3328 or http+unix:/run/moefile.sock
+func (a Addr) URL(scheme string) string {
+	if a.Network == "tcp" {
+		return scheme + "://" + a.Address
+	}
+	return scheme + "+" + a.String()
+}
+
+// Parse splits the comma separated addresses, each can be host:port, unix:<path>, systemd or systemd:<name>
+func Parse(spec string) ([]Addr, error) {
+	addrs := make([]Addr, 0)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		switch {
+		case item == "":
+			continue
+		case strings.HasPrefix(item, PrefixUnix):
+			path := strings.TrimPrefix(item, PrefixUnix)
+			if path == "" {
+				return nil, fmt.Errorf("empty unix socket path: %s", item)
+			}
+			addrs = append(addrs, Addr{Network: "unix", Address: path})
+		case item == PrefixSystemd || strings.HasPrefix(item, PrefixSystemd+":"):
+			name := strings.TrimPrefix(strings.TrimPrefix(item, PrefixSystemd), ":")
+			addrs = append(addrs, Addr{Network: PrefixSystemd, Address: name})
+		default:
+			_, _, err := net.SplitHostPort(item)
+			if err != nil {
+				return nil, err
+			}
+			addrs = append(addrs, Addr{Network: "tcp", Address: item})
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, errors.New("no listen address")
+	}
+	return addrs, nil
+}
+
+func ParseSocketMode(s string) (fs.FileMode, error) {
+	if s == "" {
+		return DefaultSocketMode, nil
+	}
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || mode > 0o777 {
+		return 0, fmt.Errorf("invalid socket mode: %s", s)
+	}
+	return fs.FileMode(mode), nil
+}
+
+// Listen opens all addresses, the listeners opened before an error are closed
+func Listen(addrs []Addr, mode fs.FileMode) ([]net.Listener, error) {
+	listeners := make([]net.Listener, 0, len(addrs))
+	for _, addr := range addrs {
+		ls, err := listen(addr, mode)
+		if err != nil {
+			for _, l := range listeners {
+				//nolint:errcheck
+				l.Close()
+			}
+			return nil, fmt.Errorf("%s: %w", addr, err)
+		}
+		listeners = append(listeners, ls...)
+	}
+	return listeners, nil
+}
+
+func listen(addr Addr, mode fs.FileMode) ([]net.Listener, error) {
+	switch addr.Network {
+	case "unix":
+		l, err := listenUnix(addr.Address, mode)
+		if err != nil {
+			return nil, err
+		}
+		return []net.Listener{l}, nil
+	case PrefixSystemd:
+		return Activated(addr.Address)
+	}
+	l, err := net.Listen(addr.Network, addr.Address)
+	if err != nil {
+		return nil, err
+	}
+	return []net.Listener{l}, nil
+}
+
+func listenUnix(path string, mode fs.FileMode) (net.Listener, error) {
+	// remove the stale socket left by an unclean exit, but never other files
+	info, err := os.Lstat(path)
+	if err == nil && info.Mode()&fs.ModeSocket != 0 {
+		err = os.Remove(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, err
+	}
+	err = os.Chmod(path, mode)
+	if err != nil {
+		//nolint:errcheck
+		l.Close()
+		return nil, err
+	}
+	return l, nil
+}
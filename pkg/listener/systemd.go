@@ -0,0 +1,100 @@
+package listener
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const systemdFirstFD = 3
+
+var (
+	ErrNotActivated = errors.New("no sockets passed by systemd")
+
+	activated     []activatedListener
+	activatedOnce sync.Once
+	taken         = make(map[int]bool)
+)
+
+type activatedListener struct {
+	name     string
+	listener net.Listener
+	err      error
+}
+
+// loadActivated is called on first use rather than in init, so that importing the package leaves the environment as it is
+func loadActivated() {
+	activatedOnce.Do(func() {
+		activated = readActivated()
+	})
+}
+
+// IsActivated reports whether the process is started by systemd socket activation
+func IsActivated() bool {
+	loadActivated()
+	return len(activated) > 0
+}
+
+// Activated returns the sockets passed by systemd, filtered by the FileDescriptorName if name is not empty
+func Activated(name string) ([]net.Listener, error) {
+	loadActivated()
+	listeners := make([]net.Listener, 0)
+	for i, a := range activated {
+		if taken[i] || (name != "" && a.name != name) {
+			continue
+		}
+		if a.err != nil {
+			return nil, a.err
+		}
+		taken[i] = true
+		listeners = append(listeners, a.listener)
+	}
+	if len(listeners) == 0 {
+		if name != "" {
+			return nil, fmt.Errorf("%w: %s", ErrNotActivated, name)
+		}
+		return nil, ErrNotActivated
+	}
+	return listeners, nil
+}
+
+// readActivated reads the sd_listen_fds(3) environment, and unsets it so that child processes do not inherit it
+func readActivated() []activatedListener {
+	defer func() {
+		//nolint:errcheck
+		os.Unsetenv("LISTEN_PID")
+		//nolint:errcheck
+		os.Unsetenv("LISTEN_FDS")
+		//nolint:errcheck
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+
+	listeners := make([]activatedListener, 0, n)
+	for i := 0; i < n; i++ {
+		fd := systemdFirstFD + i
+		a := activatedListener{name: strconv.Itoa(fd)}
+		if i < len(names) && names[i] != "" {
+			a.name = names[i]
+		}
+		f := os.NewFile(uintptr(fd), a.name)
+		a.listener, a.err = net.FileListener(f)
+		//nolint:errcheck
+		f.Close()
+		listeners = append(listeners, a)
+	}
+	return listeners
+}
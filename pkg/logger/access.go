@@ -0,0 +1,246 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+	clfEmpty      = "-"
+)
+
+type AccessRecord struct {
+	Time      time.Time
+	ClientIP  string
+	User      string
+	Method    string
+	Path      string
+	Query     string
+	Proto     string
+	Host      string
+	Status    int
+	Bytes     int64
+	Latency   time.Duration
+	Referer   string
+	UserAgent string
+	Range     string
+	Bucket    string
+	RequestID string
+}
+
+type AccessFormat interface {
+	Header() []byte
+	Format(r *AccessRecord) []byte
+}
+
+type CommonFormat struct{}
+
+type CombinedFormat struct{}
+
+type W3CFormat struct {
+	Software string
+}
+
+type JSONAccessFormat struct{}
+
+type S3AccessFormat struct{}
+
+func NewAccessFormat(name, software string) (AccessFormat, error) {
+	switch strings.ToLower(name) {
+	case "common", "clf":
+		return CommonFormat{}, nil
+	case "combined":
+		return CombinedFormat{}, nil
+	case "w3c":
+		return W3CFormat{Software: software}, nil
+	case "json":
+		return JSONAccessFormat{}, nil
+	case "s3":
+		return S3AccessFormat{}, nil
+	default:
+		return nil, fmt.Errorf("unknown access log format: %s", name)
+	}
+}
+
+func (r *AccessRecord) RequestURI() string {
+	if r.Query == "" {
+		return r.Path
+	}
+	return r.Path + "?" + r.Query
+}
+
+func (CommonFormat) Header() []byte {
+	return nil
+}
+
+// Format writes the NCSA Common Log Format: host ident authuser [date] "request" status bytes
+func (CommonFormat) Format(r *AccessRecord) []byte {
+	return []byte(clfLine(r) + "\n")
+}
+
+func (CombinedFormat) Header() []byte {
+	return nil
+}
+
+// Format writes the NCSA Combined Log Format, the Common Log Format with "referer" "user-agent"
+func (CombinedFormat) Format(r *AccessRecord) []byte {
+	return []byte(fmt.Sprintf("%s %s %s\n", clfLine(r), clfQuote(r.Referer), clfQuote(r.UserAgent)))
+}
+
+func (f W3CFormat) Header() []byte {
+	return []byte(fmt.Sprintf("#Version: 1.0\n#Software: %s\n#Date: %s\n#Fields: %s\n",
+		f.Software,
+		time.Now().UTC().Format("2006-01-02 15:04:05"),
+		"date time c-ip cs-username cs-method cs-uri-stem cs-uri-query sc-status sc-bytes "+
+			"time-taken cs-host cs(User-Agent) cs(Referer) cs(Range)",
+	))
+}
+
+// Format writes the W3C Extended Log Format, with UTC time and time-taken in seconds
+func (W3CFormat) Format(r *AccessRecord) []byte {
+	t := r.Time.UTC()
+	return []byte(strings.Join([]string{
+		t.Format("2006-01-02"),
+		t.Format("15:04:05"),
+		w3cValue(r.ClientIP),
+		w3cValue(r.User),
+		w3cValue(r.Method),
+		w3cValue(r.Path),
+		w3cValue(r.Query),
+		strconv.Itoa(r.Status),
+		strconv.FormatInt(r.Bytes, 10),
+		strconv.FormatFloat(r.Latency.Seconds(), 'f', 3, 64),
+		w3cValue(r.Host),
+		w3cValue(r.UserAgent),
+		w3cValue(r.Referer),
+		w3cValue(r.Range),
+	}, " ") + "\n")
+}
+
+func (JSONAccessFormat) Header() []byte {
+	return nil
+}
+
+func (JSONAccessFormat) Format(r *AccessRecord) []byte {
+	buf, err := json.Marshal(struct {
+		Timestamp string  `json:"timestamp"`
+		ClientIP  string  `json:"client_ip"`
+		User      string  `json:"user,omitempty"`
+		Method    string  `json:"method"`
+		Path      string  `json:"path"`
+		Query     string  `json:"query,omitempty"`
+		Proto     string  `json:"proto"`
+		Host      string  `json:"host"`
+		Status    int     `json:"status"`
+		Bytes     int64   `json:"bytes"`
+		Latency   float64 `json:"latency"`
+		Referer   string  `json:"referer,omitempty"`
+		UserAgent string  `json:"user_agent,omitempty"`
+		Range     string  `json:"range,omitempty"`
+		RequestID string  `json:"request_id,omitempty"`
+	}{
+		Timestamp: r.Time.Format(time.RFC3339Nano),
+		ClientIP:  r.ClientIP,
+		User:      r.User,
+		Method:    r.Method,
+		Path:      r.Path,
+		Query:     r.Query,
+		Proto:     r.Proto,
+		Host:      r.Host,
+		Status:    r.Status,
+		Bytes:     r.Bytes,
+		Latency:   r.Latency.Seconds(),
+		Referer:   r.Referer,
+		UserAgent: r.UserAgent,
+		Range:     r.Range,
+		RequestID: r.RequestID,
+	})
+	if err != nil {
+		return nil
+	}
+	return append(buf, '\n')
+}
+
+func (S3AccessFormat) Header() []byte {
+	return nil
+}
+
+// Format writes the Amazon S3 server access log format, fields unknown to MoeFile are "-"
+func (S3AccessFormat) Format(r *AccessRecord) []byte {
+	resource := "OBJECT"
+	if strings.HasSuffix(r.Path, "/") {
+		resource = "BUCKET"
+	}
+	key := strings.TrimPrefix(r.Path, "/")
+	if resource == "BUCKET" {
+		key = ""
+	}
+
+	return []byte(strings.Join([]string{
+		clfEmpty,
+		clfValue(r.Bucket),
+		"[" + r.Time.Format(clfTimeFormat) + "]",
+		clfValue(r.ClientIP),
+		clfValue(r.User),
+		clfValue(r.RequestID),
+		fmt.Sprintf("REST.%s.%s", r.Method, resource),
+		clfValue(key),
+		clfQuote(fmt.Sprintf("%s %s %s", r.Method, r.RequestURI(), r.Proto)),
+		strconv.Itoa(r.Status),
+		clfEmpty,
+		strconv.FormatInt(r.Bytes, 10),
+		clfEmpty,
+		strconv.FormatInt(r.Latency.Milliseconds(), 10),
+		clfEmpty,
+		clfQuote(r.Referer),
+		clfQuote(r.UserAgent),
+		clfEmpty,
+		clfEmpty,
+		clfEmpty,
+		clfEmpty,
+		clfEmpty,
+		clfValue(r.Host),
+		clfEmpty,
+	}, " ") + "\n")
+}
+
+func clfLine(r *AccessRecord) string {
+	bytes := clfEmpty
+	if r.Bytes > 0 {
+		bytes = strconv.FormatInt(r.Bytes, 10)
+	}
+	return fmt.Sprintf("%s - %s [%s] %s %d %s",
+		clfValue(r.ClientIP),
+		clfValue(r.User),
+		r.Time.Format(clfTimeFormat),
+		clfQuote(fmt.Sprintf("%s %s %s", r.Method, r.RequestURI(), r.Proto)),
+		r.Status,
+		bytes,
+	)
+}
+
+func clfValue(v string) string {
+	if v == "" {
+		return clfEmpty
+	}
+	return strings.ReplaceAll(v, " ", "%20")
+}
+
+func clfQuote(v string) string {
+	if v == "" {
+		return `"-"`
+	}
+	v = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`).Replace(v)
+	return `"` + v + `"`
+}
+
+func w3cValue(v string) string {
+	if v == "" {
+		return clfEmpty
+	}
+	return strings.NewReplacer(" ", "+", "\n", "+", "\r", "+", "\t", "+").Replace(v)
+}
@@ -0,0 +1,68 @@
+package logger
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/mattn/go-isatty"
+)
+
+const (
+	ColorAuto = ColorMode(iota)
+	ColorAlways
+	ColorNever
+)
+
+type ColorMode uint8
+
+func ParseColorMode(s string) (ColorMode, error) {
+	switch strings.ToLower(s) {
+	case "", "auto":
+		return ColorAuto, nil
+	case "always", "on", "true":
+		return ColorAlways, nil
+	case "never", "off", "false":
+		return ColorNever, nil
+	default:
+		return ColorAuto, fmt.Errorf("unknown color mode: %s", s)
+	}
+}
+
+func IsTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	return isatty.IsTerminal(f.Fd()) || isatty.IsCygwinTerminal(f.Fd())
+}
+
+// ShouldColor follows https://no-color.org and https://force-color.org in auto mode
+func ShouldColor(mode ColorMode, w io.Writer) bool {
+	switch mode {
+	case ColorAlways:
+		return true
+	case ColorNever:
+		return false
+	}
+
+	if os.Getenv("NO_COLOR") != "" {
+		return false
+	}
+	if force := os.Getenv("FORCE_COLOR"); force != "" && force != "0" && force != "false" {
+		return true
+	}
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return IsTerminal(w)
+}
+
+// Colorize wraps s in the color escapes, or returns it as is when the logger is not colored
+func (l *Logger) Colorize(color LogColor, s string) string {
+	if !l.Color || color == "" {
+		return s
+	}
+	return string(color) + s + string(CReset)
+}
@@ -0,0 +1,160 @@
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const badKey = "!BADKEY"
+
+type Formatter interface {
+	Format(e *Entry) []byte
+}
+
+type Entry struct {
+	Time    time.Time
+	Level   LogLevel
+	Tag     *Tag
+	Message string
+	Fields  []Field
+}
+
+type Field struct {
+	Key   string
+	Value any
+}
+
+type TextFormatter struct{}
+
+type JSONFormatter struct{}
+
+type jsonEntry struct {
+	Timestamp string         `json:"timestamp"`
+	Level     string         `json:"level"`
+	Tag       string         `json:"tag"`
+	Message   string         `json:"message"`
+	Fields    map[string]any `json:"fields,omitempty"`
+}
+
+func NewFormatter(name string) (Formatter, error) {
+	switch strings.ToLower(name) {
+	case "", "text":
+		return TextFormatter{}, nil
+	case "json":
+		return JSONFormatter{}, nil
+	default:
+		return nil, fmt.Errorf("unknown log format: %s", name)
+	}
+}
+
+// Fields converts alternating key/value pairs to fields, in the same manner as log/slog
+func Fields(kv ...any) []Field {
+	fields := make([]Field, 0, len(kv)/2)
+	for i := 0; i < len(kv); i++ {
+		switch k := kv[i].(type) {
+		case Field:
+			fields = append(fields, k)
+		case string:
+			if i+1 >= len(kv) {
+				fields = append(fields, Field{Key: badKey, Value: k})
+				break
+			}
+			fields = append(fields, Field{Key: k, Value: kv[i+1]})
+			i++
+		default:
+			fields = append(fields, Field{Key: badKey, Value: k})
+		}
+	}
+	return fields
+}
+
+func (TextFormatter) Format(e *Entry) []byte {
+	lines := strings.Split(e.Message, "\n")
+	prefix := fmt.Sprintf("%s %s - [%s] ",
+		e.Time.Local().Format(e.Tag.Logger.TimeFormat), e.Tag.Logger.Colorize(LevelColorMap[e.Level], LevelNameMap[e.Level]), e.Tag.ColoredName())
+	suffix := formatTextFields(e.Fields)
+
+	buf := make([]byte, 0, len(lines)*(len(prefix)+64)+len(suffix))
+	for i, line := range lines {
+		buf = append(buf, prefix...)
+		buf = append(buf, line...)
+		if i == len(lines)-1 {
+			buf = append(buf, suffix...)
+		}
+		buf = append(buf, '\n')
+	}
+	return buf
+}
+
+func (JSONFormatter) Format(e *Entry) []byte {
+	entry := jsonEntry{
+		Timestamp: e.Time.Format(time.RFC3339Nano),
+		Level:     LevelNameMap[e.Level],
+		Tag:       e.Tag.Name,
+		Message:   e.Message,
+	}
+	if len(e.Fields) > 0 {
+		entry.Fields = make(map[string]any, len(e.Fields))
+		for _, f := range e.Fields {
+			entry.Fields[f.Key] = jsonValue(f.Value)
+		}
+	}
+
+	buf, err := json.Marshal(entry)
+	if err != nil {
+		buf, _ = json.Marshal(jsonEntry{
+			Timestamp: entry.Timestamp,
+			Level:     entry.Level,
+			Tag:       entry.Tag,
+			Message:   fmt.Sprintf("%s (unable to marshal fields: %v)", e.Message, err),
+		})
+	}
+	return append(buf, '\n')
+}
+
+func formatTextFields(fields []Field) string {
+	if len(fields) == 0 {
+		return ""
+	}
+	sb := strings.Builder{}
+	for _, f := range fields {
+		v := fmt.Sprint(textValue(f.Value))
+		if v == "" || strings.ContainsAny(v, " \t\n\"=") {
+			v = strconv.Quote(v)
+		}
+		sb.WriteString(" ")
+		sb.WriteString(f.Key)
+		sb.WriteString("=")
+		sb.WriteString(v)
+	}
+	return sb.String()
+}
+
+func textValue(v any) any {
+	switch v := v.(type) {
+	case time.Duration:
+		return v.String()
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case error:
+		return v.Error()
+	default:
+		return v
+	}
+}
+
+func jsonValue(v any) any {
+	switch v := v.(type) {
+	case time.Duration:
+		return v.String()
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	default:
+		return v
+	}
+}
@@ -0,0 +1,93 @@
+package logger
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+const TagLevelDefault = "*"
+
+type TagLevels map[string]LogLevel
+
+func ParseLevel(s string) (LogLevel, error) {
+	for level, name := range LevelNameMap {
+		if strings.EqualFold(s, name) {
+			return level, nil
+		}
+	}
+	return LInf, fmt.Errorf("unknown log level: %s", s)
+}
+
+// ParseTagLevels parses specs like "server/player=dbg,url=wrn", separated by comma or new line.
+// Empty lines and lines starting with '#' are ignored, and the tag "*" sets the default level.
+func ParseTagLevels(spec string) (TagLevels, error) {
+	levels := TagLevels{}
+	for _, line := range strings.Split(spec, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, item := range strings.Split(line, ",") {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+			tag, name, ok := strings.Cut(item, "=")
+			if !ok {
+				return nil, fmt.Errorf("invalid tag level <%s>, expected <tag>=<level>", item)
+			}
+			level, err := ParseLevel(strings.TrimSpace(name))
+			if err != nil {
+				return nil, err
+			}
+			levels[strings.Trim(strings.TrimSpace(tag), "/")] = level
+		}
+	}
+	return levels, nil
+}
+
+func (tl TagLevels) String() string {
+	items := make([]string, 0, len(tl))
+	for _, tag := range slices.Sorted(maps.Keys(tl)) {
+		items = append(items, fmt.Sprintf("%s=%s", tag, strings.ToLower(LevelNameMap[tl[tag]])))
+	}
+	return strings.Join(items, ",")
+}
+
+func (l *Logger) SetTagLevels(levels TagLevels) *Logger {
+	l.tagLevels.Store(&levels)
+	return l
+}
+
+func (l *Logger) GetTagLevels() TagLevels {
+	if levels := l.tagLevels.Load(); levels != nil {
+		return *levels
+	}
+	return TagLevels{}
+}
+
+// LevelOf returns the minimal level of a tag, the longest matching tag prefix wins
+func (l *Logger) LevelOf(tag string) LogLevel {
+	levels := l.tagLevels.Load()
+	if levels == nil {
+		return l.MinLevel
+	}
+
+	result, matched := l.MinLevel, -1
+	if level, ok := (*levels)[TagLevelDefault]; ok {
+		result = level
+	}
+	for prefix, level := range *levels {
+		if len(prefix) <= matched || !matchTagPrefix(tag, prefix) {
+			continue
+		}
+		result, matched = level, len(prefix)
+	}
+	return result
+}
+
+func matchTagPrefix(tag, prefix string) bool {
+	return tag == prefix || strings.HasPrefix(tag, prefix+"/")
+}
@@ -1,10 +1,12 @@
 package logger
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
@@ -42,15 +44,20 @@ var (
 
 type Logger struct {
 	Writer     io.Writer
+	Formatter  Formatter
 	TimeFormat string
 	TagColor   map[string]LogColor
-	MinLevel   LogLevel
+	// Color enables the color escapes of levels and tags, the message is written as it is
+	Color     bool
+	MinLevel  LogLevel
+	tagLevels atomic.Pointer[TagLevels]
 }
 
 type Tag struct {
 	Name   string
 	Color  LogColor
 	Logger *Logger
+	Fields []Field
 }
 
 type LogColor string
@@ -59,6 +66,7 @@ type LogLevel uint8
 func New(w io.Writer) *Logger {
 	return &Logger{
 		Writer:     w,
+		Formatter:  TextFormatter{},
 		TimeFormat: time.RFC3339,
 	}
 }
@@ -66,9 +74,35 @@ func New(w io.Writer) *Logger {
 func NewStdout() *Logger {
 	l := New(os.Stdout)
 	l.MinLevel = LInf
+	l.Color = ShouldColor(ColorAuto, os.Stdout)
 	return l
 }
 
+func (l *Logger) SetAsync(size int) *Logger {
+	if _, ok := l.Writer.(*AsyncWriter); !ok {
+		l.Writer = NewAsyncWriter(l.Writer, size)
+	}
+	return l
+}
+
+func (l *Logger) Flush() {
+	if aw, ok := l.Writer.(*AsyncWriter); ok {
+		aw.Flush()
+	}
+}
+
+func (l *Logger) Close() error {
+	aw, ok := l.Writer.(*AsyncWriter)
+	if !ok {
+		return closeWriter(l.Writer)
+	}
+	err := aw.Close()
+	if dropped := aw.Dropped(); dropped > 0 {
+		l.Tag("log").Wrnf("Async writer dropped %d messages because the queue was full", dropped)
+	}
+	return errors.Join(err, closeWriter(aw.Unwrap()))
+}
+
 func (l *Logger) AddTagColor(prefix string, color LogColor) *Logger {
 	l.TagColor[prefix] = color
 	return l
@@ -79,6 +113,11 @@ func (l *Logger) SetTagColor(m map[string]LogColor) *Logger {
 	return l
 }
 
+func (l *Logger) SetFormatter(f Formatter) *Logger {
+	l.Formatter = f
+	return l
+}
+
 func (l *Logger) SetTimeFormat(format string) *Logger {
 	l.TimeFormat = format
 	return l
@@ -103,47 +142,51 @@ func (l *Logger) Tag(tag string) *Tag {
 }
 
 func (tag *Tag) ColoredName() string {
-	return fmt.Sprintf("%s%s%s", tag.Color, tag.Name, CReset)
+	return tag.Logger.Colorize(tag.Color, tag.Name)
+}
+
+func (tag *Tag) With(kv ...any) *Tag {
+	t := *tag
+	t.Fields = append(append([]Field{}, tag.Fields...), Fields(kv...)...)
+	return &t
 }
 
 func (tag *Tag) Logf(level LogLevel, format string, a ...any) {
-	if level < tag.Logger.MinLevel {
+	if level < tag.Logger.LevelOf(tag.Name) {
+		return
+	}
+	tag.write(time.Now(), level, fmt.Sprintf(format, a...), tag.Fields)
+}
+
+func (tag *Tag) Logw(level LogLevel, msg string, kv ...any) {
+	if level < tag.Logger.LevelOf(tag.Name) {
 		return
 	}
-	lines := strings.Split(fmt.Sprintf(format, a...), "\n")
-	for _, line := range lines {
-		c := fmt.Sprintf("%s %s - [%s] %s\n",
-			tag.Logger.GetFormatedTime(),
-			level.Colored(),
-			tag.ColoredName(),
-			line,
-		)
-		//nolint:errcheck
-		tag.Logger.Writer.Write([]byte(c))
+	fields := tag.Fields
+	if len(kv) > 0 {
+		fields = append(append([]Field{}, tag.Fields...), Fields(kv...)...)
+	}
+	tag.write(time.Now(), level, msg, fields)
+}
+
+func (tag *Tag) write(t time.Time, level LogLevel, msg string, fields []Field) {
+	formatter := tag.Logger.Formatter
+	if formatter == nil {
+		formatter = TextFormatter{}
 	}
+	buf := formatter.Format(&Entry{
+		Time:    t,
+		Level:   level,
+		Tag:     tag,
+		Message: msg,
+		Fields:  fields,
+	})
+	//nolint:errcheck
+	writeLevel(tag.Logger.Writer, level, buf)
 }
 
 func (tag *Tag) LogWriter(level LogLevel) io.Writer {
-	r, w := io.Pipe()
-	go func() {
-		defer w.Close()
-		buf2 := []byte{}
-		buf1 := make([]byte, 1)
-		for {
-			_, err := r.Read(buf1)
-			if err != nil {
-				tag.Logger.Tag("log").Errf("LogWriter read error: %v", err)
-				break
-			}
-			if buf1[0] == '\n' {
-				tag.Logf(level, "%s", buf2)
-				buf2 = []byte{}
-			} else {
-				buf2 = append(buf2, buf1[0])
-			}
-		}
-	}()
-	return w
+	return &lineWriter{tag: tag, level: level}
 }
 
 func (tag *Tag) Dbgf(format string, a ...any) {
@@ -162,6 +205,18 @@ func (tag *Tag) Errf(format string, a ...any) {
 	tag.Logf(LErr, format, a...)
 }
 
-func (ll *LogLevel) Colored() string {
-	return fmt.Sprintf("%s%s%s", LevelColorMap[*ll], LevelNameMap[*ll], CReset)
+func (tag *Tag) Dbgw(msg string, kv ...any) {
+	tag.Logw(LDbg, msg, kv...)
+}
+
+func (tag *Tag) Infw(msg string, kv ...any) {
+	tag.Logw(LInf, msg, kv...)
+}
+
+func (tag *Tag) Wrnw(msg string, kv ...any) {
+	tag.Logw(LWrn, msg, kv...)
+}
+
+func (tag *Tag) Errw(msg string, kv ...any) {
+	tag.Logw(LErr, msg, kv...)
 }
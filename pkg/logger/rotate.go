@@ -0,0 +1,270 @@
+package logger
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	rotateTimeFormat = "20060102-150405"
+	rotateRetryDelay = time.Minute
+)
+
+type RotateOptions struct {
+	MaxSize    int64
+	Interval   time.Duration
+	MaxBackups int
+	MaxAge     time.Duration
+	Compress   bool
+	// OnOpen is called with every file opened, at start and after rotation, such as to write a header
+	OnOpen func(w io.Writer) error
+}
+
+type RotatingFile struct {
+	mu         sync.Mutex
+	path       string
+	opts       RotateOptions
+	file       *os.File
+	size       int64
+	nextRotate time.Time
+	retryAt    time.Time
+	cleanup    sync.WaitGroup
+}
+
+func NewRotatingFile(path string, opts RotateOptions) (*RotatingFile, error) {
+	rf := &RotatingFile{path: path, opts: opts}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, err
+	}
+	if err := rf.open(); err != nil {
+		return nil, err
+	}
+	return rf, nil
+}
+
+func (rf *RotatingFile) Write(p []byte) (int, error) {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+
+	if rf.file == nil {
+		return 0, os.ErrClosed
+	}
+	if rf.shouldRotate(int64(len(p))) {
+		// the current file is still usable when rotation fails, so the log goes on
+		if err := rf.rotate(); err != nil {
+			rf.retryAt = time.Now().Add(rotateRetryDelay)
+		}
+	}
+
+	n, err := rf.file.Write(p)
+	rf.size += int64(n)
+	return n, err
+}
+
+func (rf *RotatingFile) Rotate() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	return rf.rotate()
+}
+
+func (rf *RotatingFile) Close() error {
+	rf.mu.Lock()
+	defer rf.mu.Unlock()
+	defer rf.cleanup.Wait()
+
+	if rf.file == nil {
+		return nil
+	}
+	err := rf.file.Close()
+	rf.file = nil
+	return err
+}
+
+func (rf *RotatingFile) open() error {
+	file, err := os.OpenFile(rf.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if rf.opts.OnOpen != nil {
+		if err := rf.opts.OnOpen(file); err != nil {
+			file.Close()
+			return err
+		}
+	}
+	stat, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	rf.file = file
+	rf.size = stat.Size()
+	rf.nextRotate = nextRotateTime(time.Now(), rf.opts.Interval)
+	return nil
+}
+
+func (rf *RotatingFile) shouldRotate(n int64) bool {
+	if time.Now().Before(rf.retryAt) {
+		return false
+	}
+	if rf.opts.MaxSize > 0 && rf.size > 0 && rf.size+n > rf.opts.MaxSize {
+		return true
+	}
+	return !rf.nextRotate.IsZero() && !time.Now().Before(rf.nextRotate)
+}
+
+// rotate renames the file before the handles are swapped, so that a failure leaves the current file open
+func (rf *RotatingFile) rotate() error {
+	backup := rf.backupName(time.Now())
+	renameErr := os.Rename(rf.path, backup)
+	if os.IsNotExist(renameErr) {
+		renameErr = nil
+	}
+
+	// a new file when renamed, or the original path again when not
+	old := rf.file
+	if err := rf.open(); err != nil {
+		return err
+	}
+	if old != nil {
+		//nolint:errcheck
+		old.Close()
+	}
+	if renameErr != nil {
+		return renameErr
+	}
+
+	rf.cleanup.Add(1)
+	go func() {
+		defer rf.cleanup.Done()
+		if rf.opts.Compress {
+			//nolint:errcheck
+			compressFile(backup)
+		}
+		rf.removeBackups()
+	}()
+	return nil
+}
+
+func (rf *RotatingFile) backupName(t time.Time) string {
+	name := fmt.Sprintf("%s.%s", rf.path, t.Format(rotateTimeFormat))
+	for i := 1; ; i++ {
+		_, err1 := os.Stat(name)
+		_, err2 := os.Stat(name + ".gz")
+		if os.IsNotExist(err1) && os.IsNotExist(err2) {
+			return name
+		}
+		name = fmt.Sprintf("%s.%s-%d", rf.path, t.Format(rotateTimeFormat), i)
+	}
+}
+
+func (rf *RotatingFile) removeBackups() {
+	if rf.opts.MaxBackups <= 0 && rf.opts.MaxAge <= 0 {
+		return
+	}
+
+	matches, err := filepath.Glob(rf.path + ".*")
+	if err != nil {
+		return
+	}
+	matches = slices.DeleteFunc(matches, func(m string) bool { return !rf.isBackup(m) })
+
+	type backup struct {
+		path    string
+		modTime time.Time
+	}
+	backups := make([]backup, 0, len(matches))
+	for _, m := range matches {
+		stat, err := os.Stat(m)
+		if err != nil || stat.IsDir() {
+			continue
+		}
+		backups = append(backups, backup{m, stat.ModTime()})
+	}
+	slices.SortFunc(backups, func(a, b backup) int {
+		return b.modTime.Compare(a.modTime)
+	})
+
+	for i, b := range backups {
+		expired := rf.opts.MaxAge > 0 && time.Since(b.modTime) > rf.opts.MaxAge
+		exceeded := rf.opts.MaxBackups > 0 && i >= rf.opts.MaxBackups
+		if expired || exceeded {
+			//nolint:errcheck
+			os.Remove(b.path)
+		}
+	}
+}
+
+// isBackup reports whether name is made by backupName, optionally compressed
+func (rf *RotatingFile) isBackup(name string) bool {
+	suffix, ok := strings.CutPrefix(name, rf.path+".")
+	if !ok {
+		return false
+	}
+	suffix = strings.TrimSuffix(suffix, ".gz")
+	if len(suffix) < len(rotateTimeFormat) {
+		return false
+	}
+	if _, err := time.Parse(rotateTimeFormat, suffix[:len(rotateTimeFormat)]); err != nil {
+		return false
+	}
+	rest := suffix[len(rotateTimeFormat):]
+	if rest == "" {
+		return true
+	}
+	n, ok := strings.CutPrefix(rest, "-")
+	_, err := strconv.Atoi(n)
+	return ok && err == nil
+}
+
+func nextRotateTime(now time.Time, interval time.Duration) time.Time {
+	if interval <= 0 {
+		return time.Time{}
+	}
+	if interval < 24*time.Hour {
+		return now.Truncate(interval).Add(interval)
+	}
+	// Align daily or longer intervals to the local midnight
+	y, m, d := now.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, now.Location()).Add(interval)
+}
+
+func compressFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	tmp := path + ".gz.tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(dst)
+	_, err = io.Copy(gz, src)
+	if err == nil {
+		err = gz.Close()
+	}
+	if cerr := dst.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	if err = os.Rename(tmp, path+".gz"); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
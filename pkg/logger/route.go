@@ -0,0 +1,67 @@
+package logger
+
+import (
+	"errors"
+	"io"
+	"os"
+)
+
+type LevelWriter interface {
+	io.Writer
+	WriteLevel(level LogLevel, p []byte) (int, error)
+}
+
+type Route struct {
+	MinLevel LogLevel
+	Writer   io.Writer
+}
+
+type LevelRouter struct {
+	Routes []Route
+}
+
+func NewLevelRouter(routes ...Route) *LevelRouter {
+	return &LevelRouter{Routes: routes}
+}
+
+func (r *LevelRouter) Write(p []byte) (int, error) {
+	return r.WriteLevel(LErr, p)
+}
+
+func (r *LevelRouter) WriteLevel(level LogLevel, p []byte) (int, error) {
+	var errs []error
+	for _, route := range r.Routes {
+		if level < route.MinLevel {
+			continue
+		}
+		if _, err := writeLevel(route.Writer, level, p); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return len(p), errors.Join(errs...)
+}
+
+func (r *LevelRouter) Close() error {
+	var errs []error
+	for _, route := range r.Routes {
+		errs = append(errs, closeWriter(route.Writer))
+	}
+	return errors.Join(errs...)
+}
+
+func writeLevel(w io.Writer, level LogLevel, p []byte) (int, error) {
+	if lw, ok := w.(LevelWriter); ok {
+		return lw.WriteLevel(level, p)
+	}
+	return w.Write(p)
+}
+
+func closeWriter(w io.Writer) error {
+	if w == os.Stdout || w == os.Stderr {
+		return nil
+	}
+	if c, ok := w.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+type SlogHandler struct {
+	tag    *Tag
+	groups []string
+}
+
+func (l *Logger) SlogHandler(tag string) *SlogHandler {
+	return &SlogHandler{tag: l.Tag(tag)}
+}
+
+func SlogLevel(level slog.Level) LogLevel {
+	switch {
+	case level >= slog.LevelError:
+		return LErr
+	case level >= slog.LevelWarn:
+		return LWrn
+	case level >= slog.LevelInfo:
+		return LInf
+	default:
+		return LDbg
+	}
+}
+
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return SlogLevel(level) >= h.tag.Logger.LevelOf(h.tag.Name)
+}
+
+func (h *SlogHandler) Handle(_ context.Context, r slog.Record) error {
+	fields := make([]Field, 0, len(h.tag.Fields)+r.NumAttrs())
+	fields = append(fields, h.tag.Fields...)
+	r.Attrs(func(a slog.Attr) bool {
+		fields = appendAttr(fields, h.groups, a)
+		return true
+	})
+
+	tag := *h.tag
+	tag.Fields = fields
+	// the record keeps the time it was created, which may be before it is handled
+	t := r.Time
+	if t.IsZero() {
+		t = time.Now()
+	}
+	tag.write(t, SlogLevel(r.Level), r.Message, fields)
+	return nil
+}
+
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := append([]Field{}, h.tag.Fields...)
+	for _, a := range attrs {
+		fields = appendAttr(fields, h.groups, a)
+	}
+	tag := *h.tag
+	tag.Fields = fields
+	return &SlogHandler{tag: &tag, groups: h.groups}
+}
+
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &SlogHandler{tag: h.tag, groups: append(append([]string{}, h.groups...), name)}
+}
+
+func appendAttr(fields []Field, groups []string, a slog.Attr) []Field {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return fields
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			groups = append(append([]string{}, groups...), a.Key)
+		}
+		for _, ga := range a.Value.Group() {
+			fields = appendAttr(fields, groups, ga)
+		}
+		return fields
+	}
+
+	key := a.Key
+	if len(groups) > 0 {
+		key = strings.Join(groups, ".") + "." + key
+	}
+	return append(fields, Field{Key: key, Value: a.Value.Any()})
+}
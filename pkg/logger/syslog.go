@@ -0,0 +1,121 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+const (
+	SyslogFacilityUser   = 1
+	SyslogFacilityDaemon = 3
+	SyslogFacilityLocal0 = 16
+)
+
+var (
+	// journald listens on /dev/log too, through /run/systemd/journal/dev-log
+	SyslogDefaultSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+	SyslogSeverityMap = map[LogLevel]int{
+		LDbg: 7,
+		LInf: 6,
+		LWrn: 4,
+		LErr: 3,
+	}
+)
+
+type SyslogWriter struct {
+	mu       sync.Mutex
+	addr     string
+	conn     net.Conn
+	Facility int
+	AppName  string
+}
+
+func NewSyslog(addr, appName string) (*SyslogWriter, error) {
+	sw := &SyslogWriter{
+		addr:     addr,
+		Facility: SyslogFacilityDaemon,
+		AppName:  appName,
+	}
+	if sw.AppName == "" {
+		sw.AppName = filepath.Base(os.Args[0])
+	}
+	if err := sw.connect(); err != nil {
+		return nil, err
+	}
+	return sw, nil
+}
+
+func (sw *SyslogWriter) Write(p []byte) (int, error) {
+	return sw.WriteLevel(LInf, p)
+}
+
+func (sw *SyslogWriter) WriteLevel(level LogLevel, p []byte) (int, error) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	msg := sw.format(level, p)
+	if sw.conn != nil {
+		if _, err := sw.conn.Write(msg); err == nil {
+			return len(p), nil
+		}
+	}
+
+	// The syslog daemon may have been restarted, reconnect once
+	if err := sw.connect(); err != nil {
+		return 0, err
+	}
+	if _, err := sw.conn.Write(msg); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+func (sw *SyslogWriter) Close() error {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	if sw.conn == nil {
+		return nil
+	}
+	err := sw.conn.Close()
+	sw.conn = nil
+	return err
+}
+
+// format builds a RFC 3164 message, which is accepted by both rsyslog and journald
+func (sw *SyslogWriter) format(level LogLevel, p []byte) []byte {
+	priority := sw.Facility*8 + SyslogSeverityMap[level]
+	header := fmt.Sprintf("<%d>%s %s[%d]: ", priority, time.Now().Format(time.Stamp), sw.AppName, os.Getpid())
+	body := bytes.TrimRight(p, "\n")
+	return append(append([]byte(header), body...), '\n')
+}
+
+func (sw *SyslogWriter) connect() error {
+	if sw.conn != nil {
+		sw.conn.Close()
+		sw.conn = nil
+	}
+
+	addrs := SyslogDefaultSockets
+	if sw.addr != "" {
+		addrs = []string{sw.addr}
+	}
+
+	var lastErr error
+	for _, addr := range addrs {
+		for _, network := range []string{"unixgram", "unix"} {
+			conn, err := net.Dial(network, addr)
+			if err == nil {
+				sw.conn = conn
+				return nil
+			}
+			lastErr = err
+		}
+	}
+	return fmt.Errorf("unable to connect to syslog: %w", lastErr)
+}
@@ -0,0 +1,200 @@
+package logger
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	AsyncDefaultQueueSize = 4096
+	asyncBufferSize       = 64 * 1024
+)
+
+type lineWriter struct {
+	mu    sync.Mutex
+	tag   *Tag
+	level LogLevel
+	buf   []byte
+}
+
+type asyncMessage struct {
+	level   LogLevel
+	leveled bool
+	buf     []byte
+}
+
+type AsyncWriter struct {
+	w       io.Writer
+	queue   chan asyncMessage
+	flush   chan chan struct{}
+	done    chan struct{}
+	mu      sync.RWMutex
+	closed  bool
+	dropped atomic.Uint64
+	// late serializes the writes after Close, which go to w directly
+	late sync.Mutex
+}
+
+// Write splits p into lines, and keeps the trailing incomplete line until the next write
+func (lw *lineWriter) Write(p []byte) (int, error) {
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+
+	lw.buf = append(lw.buf, p...)
+	for {
+		i := bytes.IndexByte(lw.buf, '\n')
+		if i < 0 {
+			break
+		}
+		lw.tag.Logf(lw.level, "%s", lw.buf[:i])
+		lw.buf = lw.buf[i+1:]
+	}
+	if len(lw.buf) == 0 {
+		lw.buf = nil
+	}
+	return len(p), nil
+}
+
+func NewAsyncWriter(w io.Writer, size int) *AsyncWriter {
+	if size <= 0 {
+		size = AsyncDefaultQueueSize
+	}
+	aw := &AsyncWriter{
+		w:     w,
+		queue: make(chan asyncMessage, size),
+		flush: make(chan chan struct{}),
+		done:  make(chan struct{}),
+	}
+	go aw.run()
+	return aw
+}
+
+func (aw *AsyncWriter) Write(p []byte) (int, error) {
+	return aw.enqueue(asyncMessage{buf: p})
+}
+
+func (aw *AsyncWriter) WriteLevel(level LogLevel, p []byte) (int, error) {
+	return aw.enqueue(asyncMessage{level: level, leveled: true, buf: p})
+}
+
+// enqueue never blocks, it drops the message when the queue is full
+func (aw *AsyncWriter) enqueue(msg asyncMessage) (int, error) {
+	p := msg.buf
+	aw.mu.RLock()
+	defer aw.mu.RUnlock()
+	if aw.closed {
+		// run may still be writing the queue to w, the late write must follow it
+		<-aw.done
+		aw.late.Lock()
+		defer aw.late.Unlock()
+		return aw.write(aw.w, msg)
+	}
+
+	msg.buf = make([]byte, len(p))
+	copy(msg.buf, p)
+	select {
+	case aw.queue <- msg:
+	default:
+		aw.dropped.Add(1)
+	}
+	return len(p), nil
+}
+
+func (aw *AsyncWriter) write(w io.Writer, msg asyncMessage) (int, error) {
+	if msg.leveled {
+		return writeLevel(w, msg.level, msg.buf)
+	}
+	return w.Write(msg.buf)
+}
+
+func (aw *AsyncWriter) Dropped() uint64 {
+	return aw.dropped.Load()
+}
+
+func (aw *AsyncWriter) Flush() {
+	ack := make(chan struct{})
+	select {
+	case aw.flush <- ack:
+		<-ack
+	case <-aw.done:
+	}
+}
+
+func (aw *AsyncWriter) Close() error {
+	aw.mu.Lock()
+	if aw.closed {
+		aw.mu.Unlock()
+		return nil
+	}
+	aw.closed = true
+	close(aw.queue)
+	aw.mu.Unlock()
+
+	<-aw.done
+	return nil
+}
+
+func (aw *AsyncWriter) Unwrap() io.Writer {
+	return aw.w
+}
+
+func (aw *AsyncWriter) run() {
+	defer close(aw.done)
+	bw := &asyncBuffer{Writer: bufio.NewWriterSize(aw.w, asyncBufferSize), w: aw.w}
+	for {
+		select {
+		case msg, ok := <-aw.queue:
+			if !ok {
+				//nolint:errcheck
+				bw.Flush()
+				return
+			}
+			//nolint:errcheck
+			aw.write(bw, msg)
+			if len(aw.queue) == 0 {
+				//nolint:errcheck
+				bw.Flush()
+			}
+		case ack := <-aw.flush:
+			aw.drain(bw)
+			//nolint:errcheck
+			bw.Flush()
+			close(ack)
+		}
+	}
+}
+
+func (aw *AsyncWriter) drain(bw *asyncBuffer) {
+	for {
+		select {
+		case msg, ok := <-aw.queue:
+			if !ok {
+				return
+			}
+			//nolint:errcheck
+			aw.write(bw, msg)
+		default:
+			return
+		}
+	}
+}
+
+// asyncBuffer batches plain writes, but level aware writers bypass the buffer to keep the level
+type asyncBuffer struct {
+	*bufio.Writer
+	w io.Writer
+}
+
+func (ab *asyncBuffer) WriteLevel(level LogLevel, p []byte) (int, error) {
+	lw, ok := ab.w.(LevelWriter)
+	if !ok {
+		return ab.Writer.Write(p)
+	}
+	if err := ab.Writer.Flush(); err != nil {
+		return 0, err
+	}
+	return lw.WriteLevel(level, p)
+}
@@ -0,0 +1,137 @@
+package logger
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gatedSink blocks its first write until release is closed
+type gatedSink struct {
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	writes  int
+	entered chan struct{}
+	release chan struct{}
+}
+
+func newGatedSink() *gatedSink {
+	return &gatedSink{entered: make(chan struct{}), release: make(chan struct{})}
+}
+
+func (s *gatedSink) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	s.writes++
+	first := s.writes == 1
+	s.mu.Unlock()
+	if first {
+		close(s.entered)
+		<-s.release
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.Write(p)
+}
+
+func (s *gatedSink) String() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.buf.String()
+}
+
+func TestAsyncWriterFlushOnClose(t *testing.T) {
+	sink := new(bytes.Buffer)
+	aw := NewAsyncWriter(sink, 0)
+	want := new(strings.Builder)
+	for i := range 1000 {
+		line := fmt.Sprintf("line %d\n", i)
+		want.WriteString(line)
+		_, err := aw.Write([]byte(line))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := aw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if sink.String() != want.String() {
+		t.Errorf("Close lost or reordered lines, got %d bytes, want %d", sink.Len(), want.Len())
+	}
+	if aw.Dropped() != 0 {
+		t.Errorf("Dropped = %d, want 0", aw.Dropped())
+	}
+}
+
+func TestAsyncWriterDropped(t *testing.T) {
+	sink := newGatedSink()
+	aw := NewAsyncWriter(sink, 1)
+
+	// the first line is taken by the writer goroutine, the second fills the queue, the others are dropped
+	//nolint:errcheck
+	aw.Write([]byte("first\n"))
+	<-sink.entered
+	for _, line := range []string{"second\n", "third\n", "fourth\n"} {
+		_, err := aw.Write([]byte(line))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if aw.Dropped() != 2 {
+		t.Errorf("Dropped = %d, want 2", aw.Dropped())
+	}
+
+	close(sink.release)
+	err := aw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := sink.String(); got != "first\nsecond\n" {
+		t.Errorf("output = %q, want the first and second lines", got)
+	}
+}
+
+func TestAsyncWriterLateWriteAfterQueue(t *testing.T) {
+	sink := newGatedSink()
+	aw := NewAsyncWriter(sink, 0)
+	//nolint:errcheck
+	aw.Write([]byte("first\n"))
+	<-sink.entered
+	//nolint:errcheck
+	aw.Write([]byte("queued\n"))
+
+	closed := make(chan struct{})
+	go func() {
+		//nolint:errcheck
+		aw.Close()
+		close(closed)
+	}()
+	for {
+		aw.mu.RLock()
+		isClosed := aw.closed
+		aw.mu.RUnlock()
+		if isClosed {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	late := make(chan struct{})
+	go func() {
+		//nolint:errcheck
+		aw.Write([]byte("late\n"))
+		close(late)
+	}()
+	// give the late write the chance to overtake the queue
+	time.Sleep(50 * time.Millisecond)
+	close(sink.release)
+	<-closed
+	<-late
+
+	if got := sink.String(); got != "first\nqueued\nlate\n" {
+		t.Errorf("output = %q, want the late line after the queued one", got)
+	}
+}
@@ -0,0 +1,327 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+var DefaultBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+type Collector interface {
+	Collect(w *bufio.Writer)
+}
+
+type Registry struct {
+	mu         sync.RWMutex
+	collectors []Collector
+}
+
+type Desc struct {
+	Name   string
+	Help   string
+	Labels []string
+}
+
+type Counter struct {
+	bits atomic.Uint64
+}
+
+type Gauge struct {
+	bits atomic.Uint64
+}
+
+type Histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+type CounterVec struct {
+	vec[*Counter]
+}
+
+type GaugeVec struct {
+	vec[*Gauge]
+}
+
+type HistogramVec struct {
+	vec[*Histogram]
+}
+
+type GaugeFunc struct {
+	Desc
+	Kind string
+	Fn   func() float64
+}
+
+type vec[T any] struct {
+	Desc
+	mu      sync.RWMutex
+	values  map[string]T
+	labels  map[string][]string
+	newItem func() T
+}
+
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+func (r *Registry) Register(c ...Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, c...)
+}
+
+func (r *Registry) WriteTo(w io.Writer) (int64, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	cw := &countWriter{w: w}
+	bw := bufio.NewWriter(cw)
+	for _, c := range r.collectors {
+		c.Collect(bw)
+	}
+	err := bw.Flush()
+	return cw.n, err
+}
+
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", ContentType)
+		w.Header().Set("Cache-Control", "no-store")
+		//nolint:errcheck
+		r.WriteTo(w)
+	})
+}
+
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	return &CounterVec{newVec(Desc{name, help, labels}, func() *Counter { return &Counter{} })}
+}
+
+func NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	return &GaugeVec{newVec(Desc{name, help, labels}, func() *Gauge { return &Gauge{} })}
+}
+
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if buckets == nil {
+		buckets = DefaultBuckets
+	}
+	return &HistogramVec{newVec(Desc{name, help, labels}, func() *Histogram {
+		return &Histogram{buckets: buckets, counts: make([]uint64, len(buckets))}
+	})}
+}
+
+func NewGaugeFunc(name, help string, fn func() float64) *GaugeFunc {
+	return &GaugeFunc{Desc: Desc{Name: name, Help: help}, Kind: "gauge", Fn: fn}
+}
+
+func NewCounterFunc(name, help string, fn func() float64) *GaugeFunc {
+	return &GaugeFunc{Desc: Desc{Name: name, Help: help}, Kind: "counter", Fn: fn}
+}
+
+func (c *Counter) Add(v float64) {
+	addFloat(&c.bits, v)
+}
+
+func (c *Counter) Inc() {
+	c.Add(1)
+}
+
+func (c *Counter) Value() float64 {
+	return math.Float64frombits(c.bits.Load())
+}
+
+func (g *Gauge) Set(v float64) {
+	g.bits.Store(math.Float64bits(v))
+}
+
+func (g *Gauge) Add(v float64) {
+	addFloat(&g.bits, v)
+}
+
+func (g *Gauge) Inc() {
+	g.Add(1)
+}
+
+func (g *Gauge) Dec() {
+	g.Add(-1)
+}
+
+func (g *Gauge) Value() float64 {
+	return math.Float64frombits(g.bits.Load())
+}
+
+func (h *Histogram) Observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, upper := range h.buckets {
+		if v <= upper {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+func (v *vec[T]) WithLabelValues(values ...string) T {
+	if len(values) != len(v.Labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.Name, len(v.Labels), len(values)))
+	}
+	key := strings.Join(values, "\xff")
+
+	v.mu.RLock()
+	item, ok := v.values[key]
+	v.mu.RUnlock()
+	if ok {
+		return item
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if item, ok = v.values[key]; ok {
+		return item
+	}
+	item = v.newItem()
+	v.values[key] = item
+	v.labels[key] = slices.Clone(values)
+	return item
+}
+
+func (c *CounterVec) Collect(w *bufio.Writer) {
+	writeHeader(w, c.Desc, "counter")
+	c.each(func(labels []string, item *Counter) {
+		writeSample(w, c.Name, c.Labels, labels, "", "", item.Value())
+	})
+}
+
+func (g *GaugeVec) Collect(w *bufio.Writer) {
+	writeHeader(w, g.Desc, "gauge")
+	g.each(func(labels []string, item *Gauge) {
+		writeSample(w, g.Name, g.Labels, labels, "", "", item.Value())
+	})
+}
+
+func (h *HistogramVec) Collect(w *bufio.Writer) {
+	writeHeader(w, h.Desc, "histogram")
+	h.each(func(labels []string, item *Histogram) {
+		item.mu.Lock()
+		defer item.mu.Unlock()
+		for i, upper := range item.buckets {
+			writeSample(w, h.Name+"_bucket", h.Labels, labels, "le", formatFloat(upper), float64(item.counts[i]))
+		}
+		writeSample(w, h.Name+"_bucket", h.Labels, labels, "le", "+Inf", float64(item.count))
+		writeSample(w, h.Name+"_sum", h.Labels, labels, "", "", item.sum)
+		writeSample(w, h.Name+"_count", h.Labels, labels, "", "", float64(item.count))
+	})
+}
+
+func (g *GaugeFunc) Collect(w *bufio.Writer) {
+	writeHeader(w, g.Desc, g.Kind)
+	writeSample(w, g.Name, nil, nil, "", "", g.Fn())
+}
+
+func newVec[T any](desc Desc, newItem func() T) vec[T] {
+	return vec[T]{
+		Desc:    desc,
+		values:  map[string]T{},
+		labels:  map[string][]string{},
+		newItem: newItem,
+	}
+}
+
+func (v *vec[T]) each(fn func(labels []string, item T)) {
+	v.mu.RLock()
+	keys := make([]string, 0, len(v.values))
+	for key := range v.values {
+		keys = append(keys, key)
+	}
+	v.mu.RUnlock()
+	slices.Sort(keys)
+
+	for _, key := range keys {
+		v.mu.RLock()
+		item, labels := v.values[key], v.labels[key]
+		v.mu.RUnlock()
+		fn(labels, item)
+	}
+}
+
+func writeHeader(w *bufio.Writer, desc Desc, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", desc.Name, strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(desc.Help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", desc.Name, kind)
+}
+
+func writeSample(w *bufio.Writer, name string, names, values []string, extraName, extraValue string, v float64) {
+	w.WriteString(name)
+	if len(names) > 0 || extraName != "" {
+		w.WriteByte('{')
+		for i := range names {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			writeLabel(w, names[i], values[i])
+		}
+		if extraName != "" {
+			if len(names) > 0 {
+				w.WriteByte(',')
+			}
+			writeLabel(w, extraName, extraValue)
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(v))
+	w.WriteByte('\n')
+}
+
+func writeLabel(w *bufio.Writer, name, value string) {
+	w.WriteString(name)
+	w.WriteString(`="`)
+	w.WriteString(strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value))
+	w.WriteByte('"')
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(v, 'g', -1, 64)
+	}
+}
+
+func addFloat(bits *atomic.Uint64, v float64) {
+	for {
+		old := bits.Load()
+		if bits.CompareAndSwap(old, math.Float64bits(math.Float64frombits(old)+v)) {
+			return
+		}
+	}
+}
+
+type countWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (cw *countWriter) Write(p []byte) (int, error) {
+	n, err := cw.w.Write(p)
+	cw.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"bufio"
+	"runtime"
+	"time"
+)
+
+type GoCollector struct {
+	startTime time.Time
+}
+
+func NewGoCollector() *GoCollector {
+	return &GoCollector{startTime: time.Now()}
+}
+
+func (g *GoCollector) Collect(w *bufio.Writer) {
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+
+	gauge := func(name, help string, v float64) {
+		writeHeader(w, Desc{Name: name, Help: help}, "gauge")
+		writeSample(w, name, nil, nil, "", "", v)
+	}
+	counter := func(name, help string, v float64) {
+		writeHeader(w, Desc{Name: name, Help: help}, "counter")
+		writeSample(w, name, nil, nil, "", "", v)
+	}
+
+	writeHeader(w, Desc{Name: "go_info", Help: "Information about the Go environment."}, "gauge")
+	writeSample(w, "go_info", []string{"version"}, []string{runtime.Version()}, "", "", 1)
+	gauge("go_goroutines", "Number of goroutines that currently exist.", float64(runtime.NumGoroutine()))
+	gauge("go_memstats_alloc_bytes", "Number of bytes allocated and still in use.", float64(ms.Alloc))
+	counter("go_memstats_alloc_bytes_total", "Total number of bytes allocated, even if freed.", float64(ms.TotalAlloc))
+	gauge("go_memstats_sys_bytes", "Number of bytes obtained from system.", float64(ms.Sys))
+	gauge("go_memstats_heap_inuse_bytes", "Number of heap bytes that are in use.", float64(ms.HeapInuse))
+	gauge("go_memstats_heap_objects", "Number of allocated objects.", float64(ms.HeapObjects))
+	counter("go_gc_cycles_total", "Number of completed GC cycles.", float64(ms.NumGC))
+	counter("go_gc_pause_seconds_total", "Total GC stop-the-world pause time in seconds.", float64(ms.PauseTotalNs)/1e9)
+	gauge("process_start_time_seconds", "Start time of the process since unix epoch in seconds.",
+		float64(g.startTime.UnixNano())/1e9)
+}
@@ -0,0 +1,184 @@
+package moefile
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"moefile/pkg/dto"
+	"moefile/res"
+)
+
+// Apache mod_autoindex sort columns and orders, as in ?C=N;O=D
+const (
+	SortColumnName        = 'N'
+	SortColumnModified    = 'M'
+	SortColumnSize        = 'S'
+	SortColumnDescription = 'D'
+
+	SortOrderAsc  = 'A'
+	SortOrderDesc = 'D'
+)
+
+// autoindexUserAgents run neither JavaScript nor XSLT, they are matched by the product name of User-Agent
+var autoindexUserAgents = []string{"wget", "rclone", "lynx", "links", "elinks", "w3m", "httrack"}
+
+var autoindexTemplate = template.Must(template.New("autoindex.html").Parse(res.AutoindexHTML))
+
+type autoindexSort struct {
+	column byte
+	order  byte
+}
+
+type autoindexLink struct {
+	Name string
+	Href string
+}
+
+type autoindexEntry struct {
+	Name     string
+	Href     string
+	Modified string
+	Size     string
+}
+
+type autoindexPage struct {
+	Path    string
+	Parent  string
+	Columns []autoindexLink
+	Entries []autoindexEntry
+	Server  string
+}
+
+// prefersAutoindex reports whether the client can not render the XML listing.
+// Browsers accept text/html as well, so text browsers are told apart by not listing XML in Accept.
+func (c *handler) prefersAutoindex() bool {
+	product := strings.FieldsFunc(c.GetHeader("User-Agent"), func(r rune) bool {
+		return r == '/' || r == ' ' || r == '('
+	})
+	if len(product) > 0 && slices.Contains(autoindexUserAgents, strings.ToLower(product[0])) {
+		return true
+	}
+
+	accept := c.GetHeader("Accept")
+	if q, exact := mediaQuality(accept, "text/html"); !exact || q == 0 {
+		return false
+	}
+	for _, mediaType := range []string{"application/xml", "text/xml"} {
+		if q, exact := mediaQuality(accept, mediaType); exact && q > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// parseAutoindexSort reads ?C= and ?O= separated by ";" or "&", it returns false when neither is set
+func parseAutoindexSort(rawQuery string) (autoindexSort, bool) {
+	s, ok := autoindexSort{column: SortColumnName, order: SortOrderAsc}, false
+	for _, part := range strings.FieldsFunc(rawQuery, func(r rune) bool { return r == ';' || r == '&' }) {
+		key, value, _ := strings.Cut(part, "=")
+		if len(value) != 1 {
+			continue
+		}
+		switch {
+		case key == "C" && strings.Contains("NMSD", value):
+			s.column, ok = value[0], true
+		case key == "O" && strings.Contains("AD", value):
+			s.order, ok = value[0], true
+		}
+	}
+	return s, ok
+}
+
+// link returns the query which sorts by column, the current column is toggled between ascending and descending
+func (s autoindexSort) link(column byte, suffix string) string {
+	order := byte(SortOrderAsc)
+	if column == s.column && s.order == SortOrderAsc {
+		order = SortOrderDesc
+	}
+	return fmt.Sprintf("?C=%c;O=%c%s", column, order, suffix)
+}
+
+func (c *handler) createHTMLFromDirInfo(info dto.DirInfo) ([]byte, error) {
+	span, end := c.span("render autoindex")
+	defer end()
+	span.SetAttr("moefile.entries", len(info.Files))
+
+	// links keep the other listing parameters, such as the filter and ?format=html of clients which have chosen it,
+	// the sort is replaced by the C= and O= of the columns
+	params := c.Request.URL.Query()
+	for _, key := range []string{"C", "O", QuerySort, QueryOrder} {
+		params.Del(key)
+	}
+	suffix := ""
+	if len(params) > 0 {
+		suffix = "&" + params.Encode()
+	}
+	sort := c.listing.autoindexSort()
+
+	page := autoindexPage{
+		Path: c.link(c.requestURL),
+		Columns: []autoindexLink{
+			{Name: "Name", Href: sort.link(SortColumnName, suffix)},
+			{Name: "Last modified", Href: sort.link(SortColumnModified, suffix)},
+			{Name: "Size", Href: sort.link(SortColumnSize, suffix)},
+		},
+		Entries: make([]autoindexEntry, 0, len(info.Files)),
+		Server:  c.serverHeader,
+	}
+	if c.requestURL != "/" {
+		page.Parent = (&url.URL{Path: c.link(path.Dir(c.requestURL))}).EscapedPath()
+		page.Parent = strings.TrimSuffix(page.Parent, "/") + "/" + strings.Replace(suffix, "&", "?", 1)
+	}
+
+	for _, f := range info.Files {
+		name, size := f.FileName, formatAutoindexSize(f.Size)
+		if f.IsDirectory {
+			name, size = name+"/", "-"
+		}
+		href := url.PathEscape(f.FileName)
+		// a colon in the first segment would be read as a scheme
+		if strings.Contains(href, ":") {
+			href = "./" + href
+		}
+		if f.IsDirectory {
+			href += "/" + strings.Replace(suffix, "&", "?", 1)
+		}
+		page.Entries = append(page.Entries, autoindexEntry{
+			Name:     name,
+			Href:     href,
+			Modified: time.Unix(f.LastModifiedUnix, 0).Format("2006-01-02 15:04"),
+			Size:     size,
+		})
+	}
+
+	buf := new(bytes.Buffer)
+	err := autoindexTemplate.Execute(buf, page)
+	if err != nil {
+		c.T("server/xml").Errf("Unable to render autoindex: %v", err)
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// formatAutoindexSize formats like Apache, e.g. 512, 1.2K, 34M
+func formatAutoindexSize(n uint64) string {
+	if n < 1024 {
+		return strconv.FormatUint(n, 10)
+	}
+	v, exp := float64(n)/1024, 0
+	for v >= 1024 && exp < 4 {
+		v /= 1024
+		exp++
+	}
+	if v < 10 {
+		return fmt.Sprintf("%.1f%c", v, "KMGTP"[exp])
+	}
+	return fmt.Sprintf("%.0f%c", v, "KMGTP"[exp])
+}
@@ -0,0 +1,50 @@
+package moefile
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+const HTTPHeaderForwardedPrefix = "X-Forwarded-Prefix"
+
+// normalizeBasePath returns the base path without trailing slash, or an empty string for the root
+func normalizeBasePath(p string) string {
+	p = path.Clean("/" + strings.TrimSpace(p))
+	if p == "/" {
+		return ""
+	}
+	return p
+}
+
+// basePath is the public path of the root, the X-Forwarded-Prefix of trusted proxies followed by -base-path
+func (s *serverConfig) basePath(r *http.Request) string {
+	prefix := r.Header.Get(HTTPHeaderForwardedPrefix)
+	if prefix == "" || strings.ContainsAny(prefix, "?#\\\"<>") || !s.isTrustedProxy(r) {
+		return s.opts.BasePath
+	}
+	return normalizeBasePath(prefix + s.opts.BasePath)
+}
+
+// stripBasePath returns the request path relative to the root, or false if it is out of the base path
+func (c *handler) stripBasePath(p string) (string, bool) {
+	if c.opts.BasePath == "" {
+		return p, true
+	}
+	rest, ok := strings.CutPrefix(p, c.opts.BasePath)
+	if !ok || (rest != "" && !strings.HasPrefix(rest, "/")) {
+		return "", false
+	}
+	if rest == "" {
+		return "/", true
+	}
+	return rest, true
+}
+
+// link builds the public URL of a path relative to the root, every generated link and redirect should use it
+func (c *handler) link(p string) string {
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	return c.basePath + p
+}
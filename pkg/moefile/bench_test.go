@@ -0,0 +1,85 @@
+package moefile_test
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"moefile/pkg/moefile"
+)
+
+func newBenchHandler(b *testing.B) http.Handler {
+	modTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"video/ep01.mp4":     {Data: make([]byte, 1<<20), ModTime: modTime},
+		"video/ep01.zh.srt":  {Data: []byte("1\n00:00:00,000 --> 00:00:01,000\nhi\n"), ModTime: modTime},
+		"video/ep01.xml":     {Data: []byte("<i></i>"), ModTime: modTime},
+		"docs/readme.txt":    {Data: []byte("hello"), ModTime: modTime},
+		"docs/notes/a.txt":   {Data: []byte("a"), ModTime: modTime},
+		"docs/notes/b.txt":   {Data: []byte("b"), ModTime: modTime},
+		"docs/notes/c/d.txt": {Data: []byte("d"), ModTime: modTime},
+	}
+	for i := range 100 {
+		fsys[fmt.Sprintf("many/file%03d.txt", i)] = &fstest.MapFile{Data: []byte("x"), ModTime: modTime}
+	}
+
+	h, err := moefile.New(fsys, moefile.Options{Compression: true, HealthChecks: true})
+	if err != nil {
+		b.Fatal(err)
+	}
+	return h
+}
+
+func benchmarkRequest(b *testing.B, h http.Handler, target string, header http.Header, want int) {
+	b.ReportAllocs()
+	b.ResetTimer()
+	for range b.N {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		for k, v := range header {
+			r.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		if w.Code != want {
+			b.Fatalf("GET %s: got status %d, want %d", target, w.Code, want)
+		}
+	}
+}
+
+func BenchmarkListing(b *testing.B) {
+	benchmarkRequest(b, newBenchHandler(b), "/many/", nil, http.StatusOK)
+}
+
+func BenchmarkListingNotModified(b *testing.B) {
+	h := newBenchHandler(b)
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/many/", nil))
+	benchmarkRequest(b, h, "/many/", http.Header{"If-None-Match": {w.Header().Get("ETag")}}, http.StatusNotModified)
+}
+
+func BenchmarkFile(b *testing.B) {
+	benchmarkRequest(b, newBenchHandler(b), "/docs/readme.txt", nil, http.StatusOK)
+}
+
+func BenchmarkFileRange(b *testing.B) {
+	benchmarkRequest(b, newBenchHandler(b), "/video/ep01.mp4", http.Header{"Range": {"bytes=1024-2047"}}, http.StatusPartialContent)
+}
+
+func BenchmarkPlayer(b *testing.B) {
+	benchmarkRequest(b, newBenchHandler(b), "/?_/player/video/ep01.mp4", nil, http.StatusOK)
+}
+
+func BenchmarkRedirect(b *testing.B) {
+	benchmarkRequest(b, newBenchHandler(b), "/docs", nil, http.StatusTemporaryRedirect)
+}
+
+func BenchmarkNotFound(b *testing.B) {
+	benchmarkRequest(b, newBenchHandler(b), "/missing", nil, http.StatusNotFound)
+}
+
+func BenchmarkHealthz(b *testing.B) {
+	benchmarkRequest(b, newBenchHandler(b), "/?_/healthz", nil, http.StatusOK)
+}
@@ -0,0 +1,29 @@
+package moefile
+
+import (
+	"net/http"
+	"strings"
+)
+
+func (c *handler) hasClientCert() bool {
+	return c.Request.TLS != nil && len(c.Request.TLS.VerifiedChains) > 0
+}
+
+func (s *serverConfig) requiresClientCert(requestURL string) bool {
+	for _, prefix := range s.opts.ClientCertPaths {
+		if prefix == "/" || requestURL == prefix || strings.HasPrefix(requestURL, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// checkClientCert aborts the request when the path requires a verified client certificate but none is given
+func (c *handler) checkClientCert(requestURL string) bool {
+	if c.hasClientCert() || !c.requiresClientCert(requestURL) {
+		return true
+	}
+	c.T("server/tls").Wrnf("Client certificate required for: %s", requestURL)
+	c.abortWithError(http.StatusForbidden, "client certificate required")
+	return false
+}
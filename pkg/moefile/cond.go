@@ -0,0 +1,62 @@
+package moefile
+
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+	"strings"
+	"time"
+
+	"moefile/internal/meta"
+	"moefile/pkg/dto"
+)
+
+func (s *serverConfig) listingValidator(dir fs.FileInfo, info dto.DirInfo, format string) (string, time.Time) {
+	lastModified := dir.ModTime()
+	for _, file := range info.Files {
+		t := time.Unix(file.LastModifiedUnix, 0)
+		if t.After(lastModified) {
+			lastModified = t
+		}
+	}
+
+	// The body also depends on the build (embedded XSLT), the format and output options
+	hash := dto.FastHash([]byte(fmt.Sprintf("%s %s %s %t %d %s",
+		meta.AppVersion, meta.BuildTimestamp, format, s.opts.XMLIndent, dir.ModTime().UnixNano(), info.Hash())))
+	return fmt.Sprintf(`W/"%s"`, hash), lastModified.Truncate(time.Second)
+}
+
+func isNotModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+
+	// RFC 9110 13.2.2: If-None-Match takes precedence over If-Modified-Since
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return matchETag(inm, etag)
+	}
+
+	ims := r.Header.Get("If-Modified-Since")
+	if ims == "" {
+		return false
+	}
+	t, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	return !lastModified.After(t)
+}
+
+func matchETag(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		// If-None-Match uses weak comparison
+		if strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,105 @@
+package moefile
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func serve(t *testing.T, h http.Handler, target string, header http.Header) *httptest.ResponseRecorder {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, target, nil)
+	for k, v := range header {
+		r.Header[k] = v
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func newTestHandler(t *testing.T, fsys fstest.MapFS) *Handler {
+	t.Helper()
+	h, err := New(fsys, Options{Compression: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func TestIsNotModified(t *testing.T) {
+	lastModified := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	before := lastModified.Add(-time.Hour).Format(http.TimeFormat)
+	after := lastModified.Add(time.Hour).Format(http.TimeFormat)
+	tests := []struct {
+		name   string
+		method string
+		header http.Header
+		want   bool
+	}{
+		{name: "no conditions", want: false},
+		{name: "etag match", header: http.Header{"If-None-Match": {`W/"abc"`}}, want: true},
+		{name: "etag mismatch", header: http.Header{"If-None-Match": {`W/"xyz"`}}, want: false},
+		{name: "weak comparison of a strong tag", header: http.Header{"If-None-Match": {`"abc"`}}, want: true},
+		{name: "etag in a list", header: http.Header{"If-None-Match": {`"xyz", W/"abc"`}}, want: true},
+		{name: "wildcard", header: http.Header{"If-None-Match": {"*"}}, want: true},
+		{name: "etag takes precedence over a matching date", header: http.Header{"If-None-Match": {`"xyz"`}, "If-Modified-Since": {after}}, want: false},
+		{name: "etag takes precedence over an older date", header: http.Header{"If-None-Match": {`"abc"`}, "If-Modified-Since": {before}}, want: true},
+		{name: "not modified since", header: http.Header{"If-Modified-Since": {after}}, want: true},
+		{name: "modified since", header: http.Header{"If-Modified-Since": {before}}, want: false},
+		{name: "invalid date", header: http.Header{"If-Modified-Since": {"yesterday"}}, want: false},
+		{name: "post", method: http.MethodPost, header: http.Header{"If-None-Match": {"*"}}, want: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			r := httptest.NewRequest(method, "/", nil)
+			r.Header = tt.header
+			if r.Header == nil {
+				r.Header = http.Header{}
+			}
+			if got := isNotModified(r, `W/"abc"`, lastModified); got != tt.want {
+				t.Errorf("isNotModified = %t, want %t", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListingValidator(t *testing.T) {
+	modTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	fsys := fstest.MapFS{
+		"dir/a.txt": {Data: []byte("a"), ModTime: modTime},
+		"dir/b.mp4": {Data: []byte("b"), ModTime: modTime},
+	}
+	h := newTestHandler(t, fsys)
+
+	first := serve(t, h, "/dir/", nil)
+	etag := first.Header().Get("ETag")
+	if first.Code != http.StatusOK || etag == "" {
+		t.Fatalf("GET /dir/: status %d, ETag %q", first.Code, etag)
+	}
+	if w := serve(t, h, "/dir/", http.Header{"If-None-Match": {etag}}); w.Code != http.StatusNotModified {
+		t.Errorf("If-None-Match with the ETag: status %d, want 304", w.Code)
+	}
+	lastModified := first.Header().Get("Last-Modified")
+	if w := serve(t, h, "/dir/", http.Header{"If-Modified-Since": {lastModified}}); w.Code != http.StatusNotModified {
+		t.Errorf("If-Modified-Since with Last-Modified: status %d, want 304", w.Code)
+	}
+
+	for _, target := range []string{"/dir/?format=json", "/dir/?format=html", "/dir/?filter=*.txt", "/dir/?sort=size"} {
+		w := serve(t, h, target, http.Header{"If-None-Match": {etag}})
+		if w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+			t.Errorf("GET %s: status %d, ETag %q, want 200 with another ETag", target, w.Code, w.Header().Get("ETag"))
+		}
+	}
+
+	fsys["dir/a.txt"] = &fstest.MapFile{Data: []byte("aa"), ModTime: modTime.Add(time.Minute)}
+	w := serve(t, h, "/dir/", http.Header{"If-None-Match": {etag}})
+	if w.Code != http.StatusOK || w.Header().Get("ETag") == etag {
+		t.Errorf("after an entry changed: status %d, ETag %q, want 200 with another ETag", w.Code, w.Header().Get("ETag"))
+	}
+}
@@ -0,0 +1,156 @@
+package moefile
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"moefile/dist"
+	"moefile/internal/meta"
+	"moefile/pkg/compress"
+	"moefile/pkg/dto"
+)
+
+func (c *handler) writeEncoded(contentType string, buf []byte) error {
+	c.Header("Content-Type", contentType)
+	if c.opts.Compression && len(buf) >= compress.MinSize && compress.IsCompressible(contentType) {
+		enc := compress.Negotiate(c.GetHeader("Accept-Encoding"), compress.Preference...)
+		encoded, err := compress.Encode(enc, buf)
+		if err != nil {
+			c.T("server/encoding").Errf("Unable to encode response with %s: %v", enc, err)
+		} else if enc != compress.EncIdentity {
+			c.T("server/encoding").Dbgf("Response encoded with %s: %d -> %d bytes", enc, len(buf), len(encoded))
+			c.Header("Content-Encoding", enc)
+			buf = encoded
+		}
+	}
+
+	c.Header("Content-Length", strconv.Itoa(len(buf)))
+	c.Status(http.StatusOK)
+	_, err := c.Writer.Write(buf)
+	return err
+}
+
+// newEncodedWriter starts a response of unknown length, compressed with the fast level when negotiated.
+// Close must be called to finish the stream.
+func (c *handler) newEncodedWriter(contentType string) (io.WriteCloser, error) {
+	c.Header("Content-Type", contentType)
+	enc := compress.EncIdentity
+	if c.opts.Compression && compress.IsCompressible(contentType) {
+		enc = compress.Negotiate(c.GetHeader("Accept-Encoding"), compress.Preference...)
+	}
+	w, err := compress.NewFastWriter(enc, c.Writer)
+	if err != nil {
+		return nil, err
+	}
+	if enc != compress.EncIdentity {
+		c.Header("Content-Encoding", enc)
+	}
+	c.Status(http.StatusOK)
+	return w, nil
+}
+
+func (c *handler) serveEmbedded(name string) error {
+	available := make([]string, 0, len(compress.Preference))
+	for _, enc := range compress.Preference {
+		if _, err := fs.Stat(dist.Embed, name+compress.SidecarExt[enc]); err == nil {
+			available = append(available, enc)
+		}
+	}
+
+	enc := compress.Negotiate(c.GetHeader("Accept-Encoding"), available...)
+	if enc == compress.EncIdentity {
+		buf, err := dist.Embed.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		http.ServeContent(c.Writer, c.Request, name, meta.BuildTime, bytes.NewReader(buf))
+		return nil
+	}
+
+	buf, err := dist.Embed.ReadFile(name + compress.SidecarExt[enc])
+	if err != nil {
+		return err
+	}
+	c.T("server/encoding").Dbgf("Serving precompressed <(vfs)/%s> with %s", name, enc)
+	c.Header("Content-Encoding", enc)
+	c.Header("Content-Type", contentTypeByName(name))
+	http.ServeContent(c.Writer, c.Request, name, meta.BuildTime, bytes.NewReader(buf))
+	return nil
+}
+
+// serveSidecar serves a precompressed copy of the original, unless it is older than the original
+func (c *handler) serveSidecar(original fs.FileInfo) bool {
+	if !c.opts.Compression {
+		return false
+	}
+
+	available := make([]string, 0, len(compress.Preference))
+	sidecars := make(map[string]fs.FileInfo, len(compress.Preference))
+	for _, enc := range compress.Preference {
+		stat, err := fs.Stat(c.rootFS, c.relPath+compress.SidecarExt[enc])
+		if err != nil || !stat.Mode().IsRegular() {
+			continue
+		}
+		if stat.ModTime().Before(original.ModTime()) {
+			c.T("server/encoding").Dbgf("Skipping stale sidecar <(wwwroot)/%s%s>", c.relPath, compress.SidecarExt[enc])
+			continue
+		}
+		available = append(available, enc)
+		sidecars[enc] = stat
+	}
+
+	enc := compress.Negotiate(c.GetHeader("Accept-Encoding"), available...)
+	if enc == compress.EncIdentity {
+		return false
+	}
+
+	name := c.relPath + compress.SidecarExt[enc]
+	file, err := c.rootFS.Open(name)
+	if err != nil {
+		c.T("server/encoding").Dbgf("Unable to open sidecar <(wwwroot)/%s>: %s", name, err)
+		return false
+	}
+	defer file.Close()
+
+	content, ok := file.(io.ReadSeeker)
+	if !ok {
+		c.T("server/encoding").Errf("Unsupported sidecar file cast to io.ReadSeeker: %T", file)
+		return false
+	}
+
+	c.T("server/encoding").Dbgf("Serving sidecar <(wwwroot)/%s> with %s", name, enc)
+	c.Header("Content-Encoding", enc)
+	c.Header("Content-Type", contentTypeByName(c.relPath))
+	c.Header("ETag", sidecarETag(original, sidecars[enc], enc))
+	http.ServeContent(c.Writer, c.Request, c.relPath, original.ModTime(), content)
+	return true
+}
+
+// fileETag is the strong ETag of the identity body
+func fileETag(stat fs.FileInfo) string {
+	return fmt.Sprintf(`"%s"`, statHash(stat))
+}
+
+// sidecarETag is the strong ETag of an encoded body, the bytes differ from the identity body and the other encodings,
+// so that a Range request with If-Range never joins parts of different encodings
+func sidecarETag(original, sidecar fs.FileInfo, enc string) string {
+	return fmt.Sprintf(`"%s-%s"`, dto.FastHash([]byte(statHash(original)+" "+statHash(sidecar))), enc)
+}
+
+func statHash(stat fs.FileInfo) string {
+	return dto.FastHash([]byte(fmt.Sprintf("%s %d %d", stat.Name(), stat.Size(), stat.ModTime().UnixNano())))
+}
+
+func contentTypeByName(name string) string {
+	contentType := mime.TypeByExtension(filepath.Ext(name))
+	if contentType == "" {
+		return "application/octet-stream"
+	}
+	return contentType
+}
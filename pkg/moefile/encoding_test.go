@@ -0,0 +1,62 @@
+package moefile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestSidecarETag(t *testing.T) {
+	modTime := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	data := []byte(strings.Repeat("moefile sidecar test\n", 200))
+	buf := new(bytes.Buffer)
+	zw := gzip.NewWriter(buf)
+	_, err := zw.Write(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = zw.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := newTestHandler(t, fstest.MapFS{
+		"a.txt":    {Data: data, ModTime: modTime},
+		"a.txt.gz": {Data: buf.Bytes(), ModTime: modTime},
+	})
+
+	gz := serve(t, h, "/a.txt", http.Header{"Accept-Encoding": {"gzip"}})
+	if gz.Code != http.StatusOK || gz.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("gzip: status %d, Content-Encoding %q", gz.Code, gz.Header().Get("Content-Encoding"))
+	}
+	identity := serve(t, h, "/a.txt", http.Header{"Accept-Encoding": {"identity"}})
+	if identity.Code != http.StatusOK || identity.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("identity: status %d, Content-Encoding %q", identity.Code, identity.Header().Get("Content-Encoding"))
+	}
+
+	gzETag, identityETag := gz.Header().Get("ETag"), identity.Header().Get("ETag")
+	if gzETag == "" || identityETag == "" || gzETag == identityETag {
+		t.Fatalf("ETags of gzip %q and identity %q must differ", gzETag, identityETag)
+	}
+	if strings.HasPrefix(gzETag, "W/") || strings.HasPrefix(identityETag, "W/") {
+		t.Errorf("ETags of gzip %q and identity %q must be strong", gzETag, identityETag)
+	}
+
+	// a download started on the gzip body must not resume with identity bytes
+	w := serve(t, h, "/a.txt", http.Header{"Accept-Encoding": {"identity"}, "Range": {"bytes=100-199"}, "If-Range": {gzETag}})
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), data) {
+		t.Errorf("identity with If-Range of gzip: status %d, %d bytes, want the whole body", w.Code, w.Body.Len())
+	}
+	w = serve(t, h, "/a.txt", http.Header{"Accept-Encoding": {"gzip"}, "Range": {"bytes=10-19"}, "If-Range": {identityETag}})
+	if w.Code != http.StatusOK || !bytes.Equal(w.Body.Bytes(), buf.Bytes()) {
+		t.Errorf("gzip with If-Range of identity: status %d, %d bytes, want the whole body", w.Code, w.Body.Len())
+	}
+
+	w = serve(t, h, "/a.txt", http.Header{"Accept-Encoding": {"gzip"}, "Range": {"bytes=10-19"}, "If-Range": {gzETag}})
+	if w.Code != http.StatusPartialContent || !bytes.Equal(w.Body.Bytes(), buf.Bytes()[10:20]) {
+		t.Errorf("gzip with its own If-Range: status %d, want 206 of the gzip body", w.Code)
+	}
+}
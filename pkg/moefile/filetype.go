@@ -0,0 +1,85 @@
+package moefile
+
+import (
+	"slices"
+	"strings"
+
+	"moefile/pkg/dto"
+)
+
+const (
+	FileTypeFolder = "folder"
+	FileTypeFile   = "file"
+)
+
+type fileTypeExts struct {
+	name string
+	exts []string
+}
+
+// fileTypes are matched by extension in order, keep them in sync with GetFileType in src/lib/directory.ts
+var fileTypes = []fileTypeExts{
+	{"document", []string{
+		"md", "txt", "rst", "rtf",
+		"doc", "docx", "xls", "xlsx", "ppt", "pptx", "pdf",
+		"odt", "ods", "odp", "odg", "odf",
+		"epub", "mobi", "djvu", "fb2",
+	}},
+	{"image", []string{
+		"jpg", "jpeg", "png", "gif", "bmp", "tif", "tiff", "svg", "ico",
+		"webp", "avif", "heif", "heic",
+		"raw", "dng", "nef", "arw", "cr2", "cr3",
+	}},
+	{"audio", []string{
+		"wav", "flac", "alac", "dsd", "ape",
+		"mp3", "aac", "ogg", "m4a", "opus",
+		"wma", "aiff", "amr", "mka", "mks",
+		"mid", "midi",
+	}},
+	{"video", []string{
+		"mp4", "mkv", "webm", "avi", "mov", "wmv", "flv", "f4v", "f4p", "f4a", "f4b",
+		"m4v", "3gp", "3g2", "ogv", "ogg", "rm", "rmvb", "m2v", "m4p", "m4b",
+		"mpg", "mpeg", "m2ts", "mts", "vob",
+	}},
+	{"code", []string{
+		"c", "cpp", "h", "hpp", "cs", "java", "js", "ts", "jsx", "tsx", "html", "css", "scss", "sass", "less",
+		"py", "rb", "php", "go", "rs", "swift", "kt", "sh", "bash", "zsh", "fish", "ps1",
+		"pl", "lua", "r", "dart", "scala", "groovy", "tsv", "csv", "yaml", "yml", "toml", "sql",
+	}},
+	{"config", []string{
+		"json", "xml", "yaml", "yml", "toml", "ini", "cfg", "conf", "properties", "csv", "sqlite", "db",
+	}},
+	{"archive", []string{
+		"zip", "tar", "gz", "bz2", "xz", "7z", "rar", "zst",
+		"pkg", "deb", "rpm", "apk", "ipa", "vhd", "vmdk", "qcow2",
+	}},
+	{"binary", []string{
+		"ova", "ovf", "iso", "img",
+		"exe", "msi", "appx", "elf",
+		"dll", "so", "dylib", "a", "lib", "bin",
+	}},
+}
+
+func fileType(f dto.FileInfo) string {
+	if f.IsDirectory {
+		return FileTypeFolder
+	}
+	// as the frontend does, a name without a dot is its own extension
+	ext := strings.ToLower(f.FileName[strings.LastIndex(f.FileName, ".")+1:])
+	if ext == "" {
+		return FileTypeFile
+	}
+	for _, t := range fileTypes {
+		if slices.Contains(t.exts, ext) {
+			return t.name
+		}
+	}
+	return FileTypeFile
+}
+
+func isFileType(name string) bool {
+	if name == FileTypeFolder || name == FileTypeFile {
+		return true
+	}
+	return slices.ContainsFunc(fileTypes, func(t fileTypeExts) bool { return t.name == name })
+}
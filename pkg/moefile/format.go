@@ -0,0 +1,148 @@
+package moefile
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"moefile/pkg/dto"
+)
+
+const (
+	QueryFormat = "format"
+
+	FormatXML    = "xml"
+	FormatJSON   = "json"
+	FormatHTML   = "html"
+	FormatText   = "txt"
+	FormatNDJSON = "ndjson"
+)
+
+type listingFormat struct {
+	name        string
+	contentType string
+	mediaTypes  []string
+	render      func(c *handler, info dto.DirInfo) ([]byte, error)
+	// line formats have a line per entry instead of render, and are streamed unless sorted
+	line func(c *handler, f dto.FileInfo) ([]byte, error)
+	// explicit formats are not negotiated by Accept quality
+	explicit bool
+}
+
+// listingFormats are in order of preference, the first one is used when the client accepts anything
+var listingFormats = []listingFormat{
+	{
+		name:        FormatXML,
+		contentType: "application/xml; charset=utf-8",
+		mediaTypes:  []string{"application/xml", "text/xml"},
+		render:      (*handler).createS3XMLFromDirInfo,
+	},
+	{
+		name:        FormatJSON,
+		contentType: "application/json; charset=utf-8",
+		mediaTypes:  []string{"application/json"},
+		render:      (*handler).createJSONFromDirInfo,
+	},
+	{
+		// browsers accept text/html first, but they are served the XML with XSLT, see prefersAutoindex
+		name:        FormatHTML,
+		contentType: "text/html; charset=utf-8",
+		mediaTypes:  []string{"text/html"},
+		render:      (*handler).createHTMLFromDirInfo,
+		explicit:    true,
+	},
+	{
+		name:        FormatText,
+		contentType: "text/plain; charset=utf-8",
+		mediaTypes:  []string{"text/plain"},
+		line:        textLine,
+	},
+	{
+		name:        FormatNDJSON,
+		contentType: "application/x-ndjson",
+		mediaTypes:  []string{"application/x-ndjson"},
+		line:        ndjsonLine,
+	},
+}
+
+// negotiateListingFormat picks the format by ?format= first, then by the client, then by the Accept header.
+// It returns false when ?format= is not supported.
+func (c *handler) negotiateListingFormat() (listingFormat, bool) {
+	if name := c.Request.URL.Query().Get(QueryFormat); name != "" {
+		return listingFormatByName(name)
+	}
+	if c.prefersAutoindex() {
+		return listingFormatByName(FormatHTML)
+	}
+
+	accept := c.GetHeader("Accept")
+	best, bestQ, bestExact := listingFormats[0], 0.0, false
+	for _, f := range listingFormats {
+		if f.explicit {
+			continue
+		}
+		for _, mediaType := range f.mediaTypes {
+			q, exact := mediaQuality(accept, mediaType)
+			// an explicit media type wins over a wildcard of the same quality, e.g. "application/json, */*"
+			if q > bestQ || (q == bestQ && q > 0 && exact && !bestExact) {
+				best, bestQ, bestExact = f, q, exact
+			}
+		}
+	}
+	return best, true
+}
+
+func listingFormatByName(name string) (listingFormat, bool) {
+	for _, f := range listingFormats {
+		if f.name == name {
+			return f, true
+		}
+	}
+	return listingFormat{}, false
+}
+
+// mediaQuality returns the quality of mediaType in the Accept header, and whether it is listed explicitly
+func mediaQuality(accept, mediaType string) (float64, bool) {
+	if strings.TrimSpace(accept) == "" {
+		return 1, false
+	}
+	mainType, _, _ := strings.Cut(mediaType, "/")
+	wildcard := 0.0
+	for _, part := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if v, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				parsed, err := strconv.ParseFloat(v, 64)
+				if err == nil {
+					q = parsed
+				}
+			}
+		}
+		if name == mediaType {
+			return q, true
+		}
+		if (name == "*/*" || name == mainType+"/*") && q > wildcard {
+			wildcard = q
+		}
+	}
+	return wildcard, false
+}
+
+func (c *handler) writeListing(f listingFormat, info dto.DirInfo) {
+	render := f.render
+	if f.line != nil {
+		render = f.renderLines
+	}
+	buf, err := render(c, info)
+	if err != nil {
+		c.abortWithError(http.StatusInternalServerError, "xml: server error")
+		return
+	}
+
+	err = c.writeEncoded(f.contentType, buf)
+	if err != nil {
+		c.T("server/xml").Errf("Unable to write %s response: %v", f.name, err)
+	}
+}
@@ -0,0 +1,103 @@
+package moefile
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestMediaQuality(t *testing.T) {
+	tests := []struct {
+		accept    string
+		mediaType string
+		q         float64
+		exact     bool
+	}{
+		{accept: "", mediaType: "application/json", q: 1},
+		{accept: "application/json", mediaType: "application/json", q: 1, exact: true},
+		{accept: "Application/JSON", mediaType: "application/json", q: 1, exact: true},
+		{accept: "application/json;q=0.5", mediaType: "application/json", q: 0.5, exact: true},
+		{accept: "application/json; charset=utf-8; q=0.7", mediaType: "application/json", q: 0.7, exact: true},
+		{accept: "application/json;q=0", mediaType: "application/json", q: 0, exact: true},
+		{accept: "application/json;q=abc", mediaType: "application/json", q: 1, exact: true},
+		{accept: "*/*", mediaType: "application/json", q: 1},
+		{accept: "*/*;q=0.2", mediaType: "application/json", q: 0.2},
+		{accept: "application/*;q=0.4, */*;q=0.1", mediaType: "application/json", q: 0.4},
+		{accept: "text/*", mediaType: "application/json", q: 0},
+		{accept: "image/png", mediaType: "application/json", q: 0},
+		// an exact entry wins over wildcards, even of a higher quality
+		{accept: "*/*, application/json;q=0.3", mediaType: "application/json", q: 0.3, exact: true},
+		{accept: "*/*, application/json;q=0", mediaType: "application/json", q: 0, exact: true},
+	}
+	for _, tt := range tests {
+		q, exact := mediaQuality(tt.accept, tt.mediaType)
+		if q != tt.q || exact != tt.exact {
+			t.Errorf("mediaQuality(%q, %q) = %v, %t, want %v, %t", tt.accept, tt.mediaType, q, exact, tt.q, tt.exact)
+		}
+	}
+}
+
+func TestNegotiateListingFormat(t *testing.T) {
+	h := newTestHandler(t, fstest.MapFS{"dir/a.txt": {Data: []byte("a"), ModTime: time.Unix(0, 0)}})
+	tests := []struct {
+		name   string
+		target string
+		header http.Header
+		want   string
+	}{
+		{name: "no accept", target: "/dir/", want: FormatXML},
+		{name: "anything", target: "/dir/", header: http.Header{"Accept": {"*/*"}}, want: FormatXML},
+		{name: "json", target: "/dir/", header: http.Header{"Accept": {"application/json"}}, want: FormatJSON},
+		{name: "json over a wildcard of the same quality", target: "/dir/", header: http.Header{"Accept": {"*/*, application/json"}}, want: FormatJSON},
+		{name: "higher quality", target: "/dir/", header: http.Header{"Accept": {"application/xml;q=0.5, application/json;q=0.9"}}, want: FormatJSON},
+		{name: "excluded by q=0", target: "/dir/", header: http.Header{"Accept": {"application/json;q=0, */*;q=0.1"}}, want: FormatXML},
+		{name: "text", target: "/dir/", header: http.Header{"Accept": {"text/plain"}}, want: FormatText},
+		{name: "ndjson", target: "/dir/", header: http.Header{"Accept": {"application/x-ndjson, application/json;q=0.5"}}, want: FormatNDJSON},
+		{name: "unmatched falls back to xml", target: "/dir/", header: http.Header{"Accept": {"image/png"}}, want: FormatXML},
+		{name: "html is never negotiated by quality", target: "/dir/", header: http.Header{"Accept": {"text/html, application/xml;q=0.9"}}, want: FormatXML},
+		{name: "text browser", target: "/dir/", header: http.Header{"Accept": {"text/html"}}, want: FormatHTML},
+		{name: "wget", target: "/dir/", header: http.Header{"User-Agent": {"Wget/1.21"}}, want: FormatHTML},
+		{name: "query over accept", target: "/dir/?format=txt", header: http.Header{"Accept": {"application/json"}}, want: FormatText},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serve(t, h, tt.target, tt.header)
+			want, _ := listingFormatByName(tt.want)
+			if w.Code != http.StatusOK || w.Header().Get("Content-Type") != want.contentType {
+				t.Errorf("status %d, Content-Type %q, want %s", w.Code, w.Header().Get("Content-Type"), want.contentType)
+			}
+		})
+	}
+
+	if w := serve(t, h, "/dir/?format=yaml", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("unsupported format: status %d, want 400", w.Code)
+	}
+}
+
+func TestListingJSON(t *testing.T) {
+	h := newTestHandler(t, fstest.MapFS{"dir/a.txt": {Data: []byte("a"), ModTime: time.Unix(0, 0)}})
+	w := serve(t, h, "/dir/", http.Header{"Accept": {"application/json"}})
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, want 200", w.Code)
+	}
+	if !strings.HasPrefix(w.Header().Get("Content-Type"), "application/json") {
+		t.Errorf("Content-Type %q, want application/json", w.Header().Get("Content-Type"))
+	}
+	vary := strings.Split(w.Header().Get("Vary"), ",")
+	found := false
+	for _, v := range vary {
+		found = found || strings.TrimSpace(v) == "Accept"
+	}
+	if !found {
+		t.Errorf("Vary %q, want Accept", w.Header().Get("Vary"))
+	}
+
+	var body map[string]any
+	err := json.Unmarshal(w.Body.Bytes(), &body)
+	if err != nil {
+		t.Fatalf("body is not JSON: %v", err)
+	}
+}
@@ -0,0 +1,103 @@
+package moefile
+
+import (
+	"strconv"
+	"time"
+
+	"moefile/pkg/httpx"
+	"moefile/pkg/metrics"
+)
+
+const (
+	HandlerNone    = "none"
+	HandlerPlayer  = "player"
+	HandlerVFS     = "vfs"
+	HandlerXML     = "xml"
+	HandlerFile    = "file"
+	HandlerStatus  = "status"
+	HandlerMetrics = "metrics"
+
+	DirWalkListing = "listing"
+	DirWalkPlayer  = "player"
+)
+
+// Metrics are the collectors of a Handler, they should be registered to a metrics.Registry
+type Metrics struct {
+	HTTPRequests    *metrics.CounterVec
+	HTTPDuration    *metrics.HistogramVec
+	BytesServed     *metrics.CounterVec
+	ActiveDownloads *metrics.GaugeVec
+	ListingCache    *metrics.CounterVec
+	DirWalkDuration *metrics.HistogramVec
+}
+
+func NewMetrics() *Metrics {
+	return &Metrics{
+		HTTPRequests: metrics.NewCounterVec("moefile_http_requests_total",
+			"Number of HTTP requests by handler and status code.", "handler", "code"),
+		HTTPDuration: metrics.NewHistogramVec("moefile_http_request_duration_seconds",
+			"HTTP request latency by handler in seconds.", nil, "handler"),
+		BytesServed: metrics.NewCounterVec("moefile_http_response_bytes_total",
+			"Number of response body bytes sent by handler.", "handler"),
+		ActiveDownloads: metrics.NewGaugeVec("moefile_active_downloads",
+			"Number of file downloads in progress."),
+		ListingCache: metrics.NewCounterVec("moefile_listing_cache_total",
+			"Number of listing requests by conditional GET result, hit for 304 Not Modified and miss otherwise.", "result"),
+		DirWalkDuration: metrics.NewHistogramVec("moefile_dir_walk_duration_seconds",
+			"Time spent reading and stating directory entries in seconds.", nil, "op"),
+	}
+}
+
+func (m *Metrics) Collectors() []metrics.Collector {
+	return []metrics.Collector{
+		m.HTTPRequests,
+		m.HTTPDuration,
+		m.BytesServed,
+		m.ActiveDownloads,
+		m.ListingCache,
+		metrics.NewGaugeFunc("moefile_listing_cache_hit_ratio",
+			"Ratio of listing requests answered with 304 Not Modified.", m.listingCacheHitRatio),
+		m.DirWalkDuration,
+	}
+}
+
+func (m *Metrics) listingCacheHitRatio() float64 {
+	hit := m.ListingCache.WithLabelValues("hit").Value()
+	miss := m.ListingCache.WithLabelValues("miss").Value()
+	if hit+miss == 0 {
+		return 0
+	}
+	return hit / (hit + miss)
+}
+
+func (m *Metrics) listingCache(hit bool) {
+	if m == nil {
+		return
+	}
+	m.ListingCache.WithLabelValues(map[bool]string{true: "hit", false: "miss"}[hit]).Inc()
+}
+
+func (m *Metrics) activeDownload(delta float64) {
+	if m == nil {
+		return
+	}
+	m.ActiveDownloads.WithLabelValues().Add(delta)
+}
+
+func (m *Metrics) observeDirWalk(op string, start time.Time) {
+	if m == nil {
+		return
+	}
+	m.DirWalkDuration.WithLabelValues(op).Observe(time.Since(start).Seconds())
+}
+
+func (m *Metrics) observeRequest(name string, w *httpx.ResponseWriter, start time.Time) {
+	if m == nil {
+		return
+	}
+	m.HTTPRequests.WithLabelValues(name, strconv.Itoa(w.Status())).Inc()
+	m.HTTPDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+	if size := w.Size(); size > 0 {
+		m.BytesServed.WithLabelValues(name).Add(float64(size))
+	}
+}
@@ -0,0 +1,110 @@
+package moefile
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"moefile/internal/meta"
+	"moefile/pkg/httpx"
+)
+
+const (
+	HTTPHeaderRequestID = "X-Request-Id"
+	RequestIDMaxLength  = 128
+)
+
+const (
+	httpAllowedMethods = "GET, HEAD, OPTIONS"
+	httpHeadersVary    = "Origin, Accept-Encoding, " + crosAllowedHeaders
+	crosAllowedMethods = httpAllowedMethods
+	crosAllowedHeaders = "Range, If-Modified-Since, If-None-Match"
+	crosExposeHeaders  = "*"
+)
+
+// crosMaxAge caches preflight responses in production builds only
+func crosMaxAge() string {
+	if meta.BuildMode == "production" {
+		return "3600"
+	}
+	return "0"
+}
+
+// requestID keeps the request ID given by a trusted proxy, otherwise a new one is generated
+func (s *serverConfig) requestID(r *http.Request) string {
+	requestID := r.Header.Get(HTTPHeaderRequestID)
+	if requestID == "" || !isValidRequestID(requestID) || !s.isTrustedProxy(r) {
+		requestID = newRequestID()
+	}
+	return requestID
+}
+
+func (c *handler) setServerInfo() {
+	c.Header("Server", c.serverHeader)
+	c.Header("Vary", httpHeadersVary)
+}
+
+// handleCROS returns true when the request is a preflight request and has been answered
+func (c *handler) handleCROS() bool {
+	origin := c.GetHeader("Origin")
+	if c.isAllowedOrigin(origin) {
+		c.Header("Access-Control-Allow-Origin", origin)
+		c.Header("Access-Control-Allow-Methods", crosAllowedMethods)
+		c.Header("Access-Control-Allow-Headers", crosAllowedHeaders)
+		c.Header("Access-Control-Expose-Headers", crosExposeHeaders)
+		c.Header("Access-Control-Max-Age", crosMaxAge())
+	}
+	if c.Request.Method == http.MethodOptions {
+		c.Status(http.StatusNoContent)
+		return true
+	}
+	return false
+}
+
+func (s *serverConfig) isAllowedOrigin(origin string) bool {
+	for _, allow := range s.opts.AllowedOrigins {
+		allow = strings.TrimSpace(allow)
+		if allow == Wildcard || allow == origin {
+			return true
+		}
+	}
+	return false
+}
+
+// handleMethodNotAllowed returns true when the method is not allowed and the request has been answered
+func (c *handler) handleMethodNotAllowed() bool {
+	for _, allowed := range strings.Split(httpAllowedMethods, ",") {
+		if c.Request.Method == strings.TrimSpace(allowed) {
+			return false
+		}
+	}
+	c.Header("Allow", httpAllowedMethods)
+	c.Status(http.StatusMethodNotAllowed)
+	return true
+}
+
+func (s *serverConfig) isTrustedProxy(r *http.Request) bool {
+	return httpx.IsTrustedPeer(r, s.opts.TrustedProxies)
+}
+
+func newRequestID() string {
+	var buf [16]byte
+	//nolint:errcheck
+	rand.Read(buf[:])
+	return hex.EncodeToString(buf[:])
+}
+
+// isValidRequestID accepts the characters used by common proxies, such as UUIDs and nginx $request_id
+func isValidRequestID(id string) bool {
+	if len(id) > RequestIDMaxLength {
+		return false
+	}
+	for _, c := range id {
+		isAlnum := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+		if !isAlnum && !strings.ContainsRune("-_.:+/=", c) {
+			return false
+		}
+	}
+	return true
+}
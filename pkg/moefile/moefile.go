@@ -0,0 +1,121 @@
+// Package moefile serves directory listings, files and the video player of a file system as an http.Handler.
+package moefile
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/netip"
+	"slices"
+	"strings"
+	"time"
+
+	"moefile/internal/meta"
+	"moefile/pkg/backend"
+	"moefile/pkg/logger"
+	"moefile/pkg/trace"
+)
+
+const Wildcard = "*"
+
+var ErrNilFS = errors.New("moefile: nil file system")
+
+type Options struct {
+	// ServerName is the bucket name in listings, defaults to the app name
+	ServerName string
+	// BasePath is the public URL path the handler is mounted at, such as /files
+	BasePath string
+	// AllowedOrigins are the CORS origins, or "*" for all
+	AllowedOrigins []string
+	// TrustedProxies may set X-Forwarded-For, X-Forwarded-Prefix, X-Request-Id and traceparent
+	TrustedProxies []netip.Prefix
+	// ClientCertPaths are the path prefixes which require a verified TLS client certificate
+	ClientCertPaths []string
+	XMLIndent       bool
+	Compression     bool
+	// ShowSources adds the layer providing each entry to listings of a union backend
+	ShowSources bool
+	// HealthChecks serves ?_/healthz and ?_/readyz
+	HealthChecks bool
+	// Status serves ?_/status to loopback clients and trusted proxies, it shows paths on disk and the version
+	Status bool
+	// MetricsHandler serves ?_/metrics to loopback clients and trusted proxies, nil to disable
+	MetricsHandler http.Handler
+
+	// Logger, Tracer and Metrics are optional, nothing is recorded when nil
+	Logger  *logger.Logger
+	Tracer  *trace.Tracer
+	Metrics *Metrics
+}
+
+// Handler is safe for concurrent use, several handlers can be used in the same process
+type Handler struct {
+	*serverConfig
+}
+
+func New(fsys fs.FS, opts Options) (*Handler, error) {
+	if fsys == nil {
+		return nil, ErrNilFS
+	}
+	if opts.ServerName == "" {
+		opts.ServerName = meta.AppName
+	}
+	opts.BasePath = normalizeBasePath(opts.BasePath)
+	// the slices are the caller's, they are copied so that neither side sees changes of the other
+	opts.AllowedOrigins = slices.Clone(opts.AllowedOrigins)
+	opts.TrustedProxies = slices.Clone(opts.TrustedProxies)
+	opts.ClientCertPaths = slices.Clone(opts.ClientCertPaths)
+	for i, p := range opts.ClientCertPaths {
+		opts.ClientCertPaths[i] = "/" + strings.Trim(p, "/")
+	}
+	if opts.Logger == nil {
+		opts.Logger = logger.New(io.Discard)
+	}
+
+	s := &serverConfig{
+		opts:      opts,
+		rootFS:    backend.Wrap(backend.KindFS, fmt.Sprintf("%T", fsys), fsys),
+		createdAt: time.Now(),
+	}
+	s.serverHeader = fmt.Sprintf("%s/%s (%s)", meta.AppName, meta.AppVersion, opts.ServerName)
+	return &Handler{serverConfig: s}, nil
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	c := &handler{
+		serverConfig: h.serverConfig,
+		Request:      r,
+		requestID:    h.requestID(r),
+		ctx:          r.Context(),
+	}
+	c.Writer = c.newResponseWriter(w)
+	c.Header(HTTPHeaderRequestID, c.requestID)
+	finish := c.startTrace()
+
+	name := c.dispatch()
+	finish(name)
+	h.opts.Metrics.observeRequest(name, c.Writer, start)
+}
+
+// ConnState counts open connections for the status page, it should be set as http.Server.ConnState
+func (h *Handler) ConnState(_ net.Conn, state http.ConnState) {
+	switch state {
+	case http.StateNew:
+		h.openConnections.Add(1)
+	case http.StateClosed, http.StateHijacked:
+		h.openConnections.Add(-1)
+	}
+}
+
+func (h *Handler) OpenConnections() int64 {
+	return h.openConnections.Load()
+}
+
+// Drain makes ?_/readyz fail, so load balancers stop sending new requests during shutdown
+func (h *Handler) Drain() {
+	h.draining.Store(true)
+}
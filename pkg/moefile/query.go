@@ -0,0 +1,248 @@
+package moefile
+
+import (
+	"cmp"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"moefile/pkg/dto"
+)
+
+const (
+	QuerySort      = "sort"
+	QueryOrder     = "order"
+	QueryNatural   = "natural"
+	QueryDirsFirst = "dirsfirst"
+	QueryFilter    = "filter"
+	QueryMatch     = "match"
+	QueryType      = "type"
+
+	SortName  = "name"
+	SortSize  = "size"
+	SortMTime = "mtime"
+
+	OrderAsc  = "asc"
+	OrderDesc = "desc"
+
+	MatchGlob  = "glob"
+	MatchRegex = "regex"
+)
+
+// listingQuery filters and sorts a listing, the zero value keeps the directory as it is read
+type listingQuery struct {
+	sort      string
+	desc      bool
+	natural   bool
+	dirsFirst bool
+	filter    func(name string) bool
+	types     []string
+	// long adds the long fields to NDJSON lines
+	long bool
+}
+
+func (c *handler) parseListingQuery() (listingQuery, error) {
+	query := c.Request.URL.Query()
+	q := listingQuery{natural: true}
+
+	// Apache ?C=N;O=D is used when sort is not set, and compares names byte-wise as Apache does
+	if s, ok := parseAutoindexSort(c.Request.URL.RawQuery); ok {
+		q.sort = SortName
+		switch s.column {
+		case SortColumnModified:
+			q.sort = SortMTime
+		case SortColumnSize:
+			q.sort = SortSize
+		}
+		q.desc, q.natural = s.order == SortOrderDesc, false
+	}
+
+	if v := query.Get(QuerySort); v != "" {
+		if !slices.Contains([]string{SortName, SortSize, SortMTime}, v) {
+			return q, fmt.Errorf("unsupported %s: %s", QuerySort, v)
+		}
+		q.sort, q.desc, q.natural = v, false, true
+	}
+	switch v := query.Get(QueryOrder); v {
+	case "":
+	case OrderAsc, OrderDesc:
+		q.desc = v == OrderDesc
+	default:
+		return q, fmt.Errorf("unsupported %s: %s", QueryOrder, v)
+	}
+
+	q.long = query.Has(QueryLong)
+	err := queryBool(query, QueryNatural, &q.natural)
+	if err != nil {
+		return q, err
+	}
+	err = queryBool(query, QueryDirsFirst, &q.dirsFirst)
+	if err != nil {
+		return q, err
+	}
+
+	if pattern := query.Get(QueryFilter); pattern != "" {
+		switch match := query.Get(QueryMatch); match {
+		case "", MatchGlob:
+			_, err := path.Match(pattern, "")
+			if err != nil {
+				return q, fmt.Errorf("invalid %s: %w", QueryFilter, err)
+			}
+			q.filter = func(name string) bool {
+				ok, _ := path.Match(pattern, name)
+				return ok
+			}
+		case MatchRegex:
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return q, fmt.Errorf("invalid %s: %w", QueryFilter, err)
+			}
+			q.filter = re.MatchString
+		default:
+			return q, fmt.Errorf("unsupported %s: %s", QueryMatch, match)
+		}
+	}
+
+	if types := query.Get(QueryType); types != "" {
+		for _, t := range strings.Split(types, ",") {
+			t = strings.ToLower(strings.TrimSpace(t))
+			if !isFileType(t) {
+				return q, fmt.Errorf("unsupported %s: %s", QueryType, t)
+			}
+			q.types = append(q.types, t)
+		}
+	}
+	return q, nil
+}
+
+func queryBool(query url.Values, key string, v *bool) error {
+	if !query.Has(key) {
+		return nil
+	}
+	// a bare ?dirsfirst is true
+	s := query.Get(key)
+	if s == "" {
+		*v = true
+		return nil
+	}
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return fmt.Errorf("invalid %s: %s", key, s)
+	}
+	*v = b
+	return nil
+}
+
+// sorted reports whether the whole directory must be read before the first entry is known
+func (q listingQuery) sorted() bool {
+	return q.sort != "" || q.dirsFirst
+}
+
+// autoindexSort is the sort of the listing as an autoindex column, by name in ascending order when it is not sorted
+func (q listingQuery) autoindexSort() autoindexSort {
+	s := autoindexSort{column: SortColumnName, order: SortOrderAsc}
+	switch q.sort {
+	case "":
+		return s
+	case SortMTime:
+		s.column = SortColumnModified
+	case SortSize:
+		s.column = SortColumnSize
+	}
+	if q.desc {
+		s.order = SortOrderDesc
+	}
+	return s
+}
+
+// match reports whether the entry passes the filter and the type, directories are filtered as well
+func (q listingQuery) match(f dto.FileInfo) bool {
+	if q.filter != nil && !q.filter(f.FileName) {
+		return false
+	}
+	return len(q.types) == 0 || slices.Contains(q.types, fileType(f))
+}
+
+func (q listingQuery) apply(info *dto.DirInfo) {
+	if q.filter != nil || len(q.types) > 0 {
+		info.Files = slices.DeleteFunc(info.Files, func(f dto.FileInfo) bool { return !q.match(f) })
+	}
+	if q.sorted() {
+		slices.SortStableFunc(info.Files, q.compare)
+	}
+}
+
+func (q listingQuery) compare(a, b dto.FileInfo) int {
+	// directories stay first in descending order too
+	if q.dirsFirst && a.IsDirectory != b.IsDirectory {
+		if a.IsDirectory {
+			return -1
+		}
+		return 1
+	}
+
+	r := 0
+	switch q.sort {
+	case "":
+		return 0
+	case SortSize:
+		r = cmp.Compare(a.Size, b.Size)
+	case SortMTime:
+		r = cmp.Compare(a.LastModifiedUnix, b.LastModifiedUnix)
+	}
+	if r == 0 && q.natural {
+		r = naturalCompare(a.FileName, b.FileName)
+	} else if r == 0 {
+		r = strings.Compare(a.FileName, b.FileName)
+	}
+	if q.desc {
+		return -r
+	}
+	return r
+}
+
+// naturalCompare compares runs of digits by their value, so that ep2 is before ep10 and v1.9 before v1.10.
+// Other characters are compared case-insensitively, the byte-wise order breaks ties such as ep01 and ep1.
+func naturalCompare(a, b string) int {
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		if isDigit(a[i]) && isDigit(b[j]) {
+			si, sj := i, j
+			for i < len(a) && isDigit(a[i]) {
+				i++
+			}
+			for j < len(b) && isDigit(b[j]) {
+				j++
+			}
+			na, nb := strings.TrimLeft(a[si:i], "0"), strings.TrimLeft(b[sj:j], "0")
+			if r := cmp.Compare(len(na), len(nb)); r != 0 {
+				return r
+			}
+			if r := strings.Compare(na, nb); r != 0 {
+				return r
+			}
+			continue
+		}
+
+		ra, sa := utf8.DecodeRuneInString(a[i:])
+		rb, sb := utf8.DecodeRuneInString(b[j:])
+		if r := cmp.Compare(unicode.ToLower(ra), unicode.ToLower(rb)); r != 0 {
+			return r
+		}
+		i, j = i+sa, j+sb
+	}
+	if r := cmp.Compare(len(a)-i, len(b)-j); r != 0 {
+		return r
+	}
+	return strings.Compare(a, b)
+}
+
+func isDigit(b byte) bool {
+	return '0' <= b && b <= '9'
+}
@@ -0,0 +1,112 @@
+package moefile
+
+import (
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"moefile/pkg/dto"
+)
+
+func TestNaturalCompare(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{"ep2", "ep10", -1},
+		{"ep10", "ep2", 1},
+		{"ep2.mkv", "ep2.mkv", 0},
+		{"v1.9", "v1.10", -1},
+		{"ep01", "ep1", -1},
+		{"ep1", "ep01", 1},
+		{"ep001", "ep01", -1},
+		{"ep01", "ep2", -1},
+		{"ep", "ep1", -1},
+		{"a", "B", -1},
+		{"A", "a", -1},
+		{"file", "File", 1},
+		{"été2", "été10", -1},
+		{"Été2", "été10", -1},
+		{"Ärger", "ärger", -1},
+		{"z", "é", -1},
+		{"日本2", "日本10", -1},
+		{"日本", "日本語", -1},
+		{"0", "00", -1},
+	}
+	for _, tt := range tests {
+		if got := naturalCompare(tt.a, tt.b); got != tt.want {
+			t.Errorf("naturalCompare(%q, %q) = %d, want %d", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestListingQuery(t *testing.T) {
+	files := []dto.FileInfo{
+		{FileName: "ep10.mkv", Size: 10, LastModifiedUnix: 2},
+		{FileName: "b", IsDirectory: true, LastModifiedUnix: 3},
+		{FileName: "Ep1.txt", Size: 20, LastModifiedUnix: 5},
+		{FileName: "a", IsDirectory: true, LastModifiedUnix: 1},
+		{FileName: "ep2.mkv", Size: 30, LastModifiedUnix: 4},
+	}
+	tests := []struct {
+		query string
+		want  []string
+		err   bool
+	}{
+		{query: "", want: []string{"ep10.mkv", "b", "Ep1.txt", "a", "ep2.mkv"}},
+		{query: "sort=name", want: []string{"a", "b", "Ep1.txt", "ep2.mkv", "ep10.mkv"}},
+		{query: "sort=name&order=desc", want: []string{"ep10.mkv", "ep2.mkv", "Ep1.txt", "b", "a"}},
+		{query: "sort=name&natural=false", want: []string{"Ep1.txt", "a", "b", "ep10.mkv", "ep2.mkv"}},
+		{query: "sort=size", want: []string{"a", "b", "ep10.mkv", "Ep1.txt", "ep2.mkv"}},
+		{query: "sort=mtime&order=asc", want: []string{"a", "ep10.mkv", "b", "ep2.mkv", "Ep1.txt"}},
+		{query: "dirsfirst", want: []string{"b", "a", "ep10.mkv", "Ep1.txt", "ep2.mkv"}},
+		{query: "dirsfirst=false&sort=name", want: []string{"a", "b", "Ep1.txt", "ep2.mkv", "ep10.mkv"}},
+		// directories stay first in descending order
+		{query: "sort=name&order=desc&dirsfirst", want: []string{"b", "a", "ep10.mkv", "ep2.mkv", "Ep1.txt"}},
+		{query: "sort=size&order=desc&dirsfirst=1", want: []string{"b", "a", "ep2.mkv", "Ep1.txt", "ep10.mkv"}},
+		{query: "C=M;O=D", want: []string{"Ep1.txt", "ep2.mkv", "b", "ep10.mkv", "a"}},
+		{query: "C=N;O=A", want: []string{"Ep1.txt", "a", "b", "ep10.mkv", "ep2.mkv"}},
+		// sort overrides the Apache column, its order and its byte-wise names
+		{query: "C=M;O=D&sort=name", want: []string{"a", "b", "Ep1.txt", "ep2.mkv", "ep10.mkv"}},
+		{query: "C=M;O=D&sort=size&order=desc", want: []string{"ep2.mkv", "Ep1.txt", "ep10.mkv", "b", "a"}},
+		{query: "filter=ep*", want: []string{"ep10.mkv", "ep2.mkv"}},
+		{query: "filter=*.mkv&match=glob&sort=name", want: []string{"ep2.mkv", "ep10.mkv"}},
+		{query: `filter=^[Ee]p[0-9]%2B\.&match=regex&sort=name`, want: []string{"Ep1.txt", "ep2.mkv", "ep10.mkv"}},
+		{query: "filter=%5E%5Ba-b%5D%24&match=regex&order=desc&sort=name", want: []string{"b", "a"}},
+		{query: "type=folder", want: []string{"b", "a"}},
+		{query: "type=video,Document&sort=name", want: []string{"Ep1.txt", "ep2.mkv", "ep10.mkv"}},
+		{query: "sort=date", err: true},
+		{query: "order=up", err: true},
+		{query: "natural=maybe", err: true},
+		{query: "dirsfirst=2", err: true},
+		{query: "filter=%5B", err: true},
+		{query: "filter=%28&match=regex", err: true},
+		{query: "filter=a&match=fuzzy", err: true},
+		{query: "type=movie", err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			c := &handler{Request: httptest.NewRequest("GET", "/dir/?"+tt.query, nil)}
+			q, err := c.parseListingQuery()
+			if tt.err {
+				if err == nil {
+					t.Fatal("want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			info := &dto.DirInfo{Files: slices.Clone(files)}
+			q.apply(info)
+			var got []string
+			for _, f := range info.Files {
+				got = append(got, f.FileName)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package moefile
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"net/http"
+	"strconv"
+
+	"moefile/pkg/httpx"
+)
+
+// newResponseWriter reuses the writer of an outer middleware, or the one allocated with the handler
+func (c *handler) newResponseWriter(w http.ResponseWriter) *httpx.ResponseWriter {
+	if rw, ok := w.(*httpx.ResponseWriter); ok {
+		return rw
+	}
+	c.rw = httpx.ResponseWriter{ResponseWriter: w}
+	return &c.rw
+}
+
+// Header sets a response header, or removes it when value is empty
+func (c *handler) Header(key, value string) {
+	if value == "" {
+		c.Writer.Header().Del(key)
+		return
+	}
+	c.Writer.Header().Set(key, value)
+}
+
+func (c *handler) GetHeader(key string) string {
+	return c.Request.Header.Get(key)
+}
+
+// Status writes the status code and the headers set so far
+func (c *handler) Status(code int) {
+	c.Writer.WriteHeader(code)
+}
+
+func (c *handler) writeBody(code int, contentType string, buf []byte) {
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Length", strconv.Itoa(len(buf)))
+	c.Status(code)
+	_, err := c.Writer.Write(buf)
+	if err != nil {
+		c.T("server").Dbgf("Unable to write response: %v", err)
+	}
+}
+
+func (c *handler) writeString(code int, s string) {
+	c.writeBody(code, "text/plain; charset=utf-8", []byte(s))
+}
+
+func (c *handler) writeXML(code int, v any) {
+	buf, err := xml.Marshal(v)
+	if err != nil {
+		c.T("server").Errf("Unable to marshal XML response: %v", err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	c.writeBody(code, "application/xml; charset=utf-8", buf)
+}
+
+func (c *handler) writeJSON(code int, v any, indent bool) {
+	var buf []byte
+	var err error
+	if indent {
+		buf, err = json.MarshalIndent(v, "", "    ")
+	} else {
+		buf, err = json.Marshal(v)
+	}
+	if err != nil {
+		c.T("server").Errf("Unable to marshal JSON response: %v", err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	c.writeBody(code, "application/json; charset=utf-8", buf)
+}
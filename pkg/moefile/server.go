@@ -0,0 +1,298 @@
+package moefile
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"moefile/internal/meta"
+	"moefile/pkg/backend"
+	"moefile/pkg/dto"
+	"moefile/pkg/httpx"
+	"moefile/pkg/logger"
+	"moefile/res"
+)
+
+const (
+	QueryPrefixVFS       = "_/"
+	QueryPrefixVFSPlayer = "_/player/"
+	QueryVFSSchema       = "_/schema.json"
+)
+
+type serverConfig struct {
+	opts            Options
+	rootFS          backend.Backend
+	serverHeader    string
+	createdAt       time.Time
+	openConnections atomic.Int64
+	draining        atomic.Bool
+}
+
+type handler struct {
+	*serverConfig
+	*urlInfo
+	Writer    *httpx.ResponseWriter
+	Request   *http.Request
+	rw        httpx.ResponseWriter
+	requestID string
+	basePath  string
+	ctx       context.Context
+	// listing is the query of a directory listing, parsed once for the renderers
+	listing listingQuery
+}
+
+// dispatch serves the request and returns the name of the handler which served it
+func (c *handler) dispatch() string {
+	c.setServerInfo()
+	if c.handleCROS() || c.handleMethodNotAllowed() {
+		return HandlerNone
+	}
+
+	// the probes are answered on any path, so that they need not know the base path
+	if c.opts.HealthChecks {
+		switch c.Request.URL.RawQuery {
+		case QueryVFSHealthz:
+			c.handleHealthz()
+			return HandlerNone
+		case QueryVFSReadyz:
+			c.handleReadyz()
+			return HandlerNone
+		}
+	}
+	return c.handle()
+}
+
+func (c *handler) handle() string {
+	c.basePath = c.serverConfig.basePath(c.Request)
+	_, end := c.span("handle")
+	defer end()
+
+	reqPath, ok := c.stripBasePath(c.Request.URL.Path)
+	if !ok {
+		c.abortWithError(http.StatusNotFound, "not found")
+		return HandlerNone
+	}
+
+	url := c.resolve(reqPath)
+	if !url.ok {
+		c.abortWithError(http.StatusNotFound, "invalid url")
+		return HandlerNone
+	}
+	c.urlInfo = &url
+
+	if !c.checkClientCert(url.requestURL) {
+		return HandlerNone
+	}
+
+	var name string
+	switch {
+	case c.handlePlayer():
+		name = HandlerPlayer
+	case c.handleStatus():
+		name = HandlerStatus
+	case c.handleMetrics():
+		name = HandlerMetrics
+	case c.handleVFS():
+		name = HandlerVFS
+	case c.handleXML():
+		name = HandlerXML
+	case c.handleFile():
+		name = HandlerFile
+	default:
+		c.abortWithError(http.StatusNotFound, "not found")
+		return HandlerNone
+	}
+	c.T("server").Dbgf("Request handled by: %s", name)
+	return name
+}
+
+func (s *serverConfig) T(name string) *logger.Tag {
+	return s.opts.Logger.Tag(name)
+}
+
+// T returns a tag carrying the request ID, for logs written while handling a request
+func (c *handler) T(name string) *logger.Tag {
+	if c.requestID == "" {
+		return c.serverConfig.T(name)
+	}
+	return c.serverConfig.T(name).With("request_id", c.requestID)
+}
+
+func (c *handler) abortWithError(code int, message string) {
+	c.writeXML(code, dto.ErrorResponse{Message: message, RequestID: c.requestID})
+}
+
+func (c *handler) handlePlayer() bool {
+	_, end := c.span("handlePlayer")
+	defer end()
+
+	query := c.Request.URL.RawQuery
+	if c.requestURL != "/" || !strings.HasPrefix(query, QueryPrefixVFSPlayer) {
+		return false
+	}
+
+	if c.Request.Method != "GET" {
+		c.Header("Allow", "GET")
+		c.abortWithError(http.StatusMethodNotAllowed, "player: method not allowed")
+		return true
+	}
+
+	playerReqURL := c.resolve("/" + strings.Trim(strings.TrimPrefix(query, QueryPrefixVFSPlayer), "/"))
+	if !playerReqURL.ok {
+		c.abortWithError(http.StatusNotFound, "player: invalid url")
+		return true
+	}
+	c.T("server/player").Dbgf("Player request URL:  %s", playerReqURL.requestURL)
+	if !c.checkClientCert(playerReqURL.requestURL) {
+		return true
+	}
+
+	data, err := c.searchPlayerData(playerReqURL.requestURL)
+	if err != nil {
+		c.T("server/player").Errf("Unable to search danmaku and subtitles (PlayerData): %v", err)
+		data = dto.PlayerData{
+			BasePath: c.link("/"),
+			Subs:     make([]dto.PlayerSub, 0),
+		}
+	}
+
+	buf, err := c.renderPlayerData(data)
+	if err != nil {
+		c.Status(http.StatusInternalServerError)
+		return true
+	}
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+	_, err = bufio.NewReader(buf).WriteTo(c.Writer)
+	if err != nil {
+		c.T("server/player").Errf("Unable to write player data to response: %v", err)
+	}
+	return true
+}
+
+func (c *handler) handleVFS() bool {
+	_, end := c.span("handleVFS")
+	defer end()
+
+	query := c.Request.URL.RawQuery
+	if c.requestURL != "/" || !strings.HasPrefix(query, QueryPrefixVFS) {
+		return false
+	}
+
+	url := strings.TrimPrefix(query, QueryPrefixVFS)
+	if query == QueryVFSSchema {
+		http.ServeContent(c.Writer, c.Request, url, meta.BuildTime, bytes.NewReader(res.Schema))
+		return true
+	}
+
+	err := c.serveEmbedded(url)
+	if err != nil {
+		c.T("server/vfs").Dbgf("Unable to open file <(vfs)/%s>: %s", url, err)
+		c.abortWithError(http.StatusNotFound, "vfs: file not found")
+		return true
+	}
+	return true
+}
+
+func (c *handler) handleXML() bool {
+	_, end := c.span("handleXML")
+	defer end()
+
+	stat, err := c.rootFS.Stat(c.relPath)
+	if err != nil {
+		c.T("server/xml").Dbgf("Unable to stat file <(wwwroot)/%s>: %s", c.relPath, err)
+		return false
+	}
+
+	if !stat.IsDir() {
+		return false
+	}
+
+	if !strings.HasSuffix(c.Request.URL.Path, "/") {
+		stdURL := c.link(strings.TrimSuffix(c.requestURL, "/") + "/")
+		if c.Request.URL.RawQuery != "" {
+			stdURL += "?" + c.Request.URL.RawQuery
+		}
+		c.T("server/xml").Dbgf("Redirecting to tailing slash URL: %s -> %s", c.Request.URL.Path, stdURL)
+		http.Redirect(c.Writer, c.Request, stdURL, http.StatusTemporaryRedirect)
+		return true
+	}
+
+	format, ok := c.negotiateListingFormat()
+	if !ok {
+		c.abortWithError(http.StatusBadRequest, "xml: unsupported format")
+		return true
+	}
+
+	query, err := c.parseListingQuery()
+	if err != nil {
+		c.abortWithError(http.StatusBadRequest, "xml: "+err.Error())
+		return true
+	}
+	c.listing = query
+
+	c.Header("Vary", httpHeadersVary+", Accept, User-Agent")
+	if format.line != nil && !query.sorted() {
+		c.streamListing(format, query)
+		return true
+	}
+
+	info, err := c.createDirInfoFromFSDir(c.requestURL, c.relPath)
+	if err != nil {
+		c.abortWithError(http.StatusInternalServerError, "xml: server error")
+		return true
+	}
+	info.BasePath = c.link("/")
+	query.apply(&info)
+
+	// the query changes the body, such as the filter or ?l of NDJSON
+	etag, lastModified := c.listingValidator(stat, info, format.name+" "+c.Request.URL.RawQuery)
+	c.Header("ETag", etag)
+	c.Header("Last-Modified", lastModified.UTC().Format(http.TimeFormat))
+	c.Header("Cache-Control", "no-cache")
+	if isNotModified(c.Request, etag, lastModified) {
+		c.T("server/xml").Dbgf("Listing not modified: %s (etag=%s)", c.requestURL, etag)
+		c.opts.Metrics.listingCache(true)
+		c.Status(http.StatusNotModified)
+		return true
+	}
+
+	c.opts.Metrics.listingCache(false)
+	c.writeListing(format, info)
+	return true
+}
+
+func (c *handler) handleFile() bool {
+	_, end := c.span("handleFile")
+	defer end()
+
+	file, err := c.rootFS.Open(c.relPath)
+	if err != nil {
+		c.T("server/file").Dbgf("Unable to open file <(wwwroot)/%s>: %s", c.relPath, err)
+		c.abortWithError(http.StatusNotFound, "file not found")
+		return true
+	}
+	stat, err := file.Stat()
+	//nolint:errcheck
+	file.Close()
+	if err != nil {
+		c.abortWithError(http.StatusNotFound, "file not found")
+		return true
+	}
+
+	c.opts.Metrics.activeDownload(1)
+	defer c.opts.Metrics.activeDownload(-1)
+
+	if ok := c.serveSidecar(stat); ok {
+		return true
+	}
+	c.Header("ETag", fileETag(stat))
+
+	http.ServeFileFS(c.Writer, c.Request, c.rootFS, c.relPath)
+	return true
+}
@@ -0,0 +1,200 @@
+package moefile
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/netip"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"moefile/dist"
+	"moefile/internal/meta"
+	"moefile/pkg/backend"
+	"moefile/pkg/diskstat"
+	"moefile/pkg/dto"
+	"moefile/pkg/httpx"
+	"moefile/res"
+)
+
+const (
+	QueryVFSStatus  = "_/status"
+	QueryVFSMetrics = "_/metrics"
+	QueryVFSHealthz = "_/healthz"
+	QueryVFSReadyz  = "_/readyz"
+)
+
+var statusTemplate = template.Must(template.New("status.html").Funcs(template.FuncMap{
+	"bytes": formatBytes,
+}).Parse(res.StatusHTML))
+
+func (c *handler) handleHealthz() {
+	c.Header("Cache-Control", "no-store")
+	c.writeString(http.StatusOK, "ok\n")
+}
+
+func (c *handler) handleReadyz() {
+	info := c.readiness()
+	c.Header("Cache-Control", "no-store")
+	if !info.Ready {
+		c.writeJSON(http.StatusServiceUnavailable, info, false)
+		return
+	}
+	c.writeJSON(http.StatusOK, info, false)
+}
+
+func (s *serverConfig) readiness() dto.ReadyInfo {
+	info := dto.ReadyInfo{Ready: true, Checks: map[string]string{}}
+	check := func(name string, err error) {
+		if err != nil {
+			info.Ready = false
+			info.Checks[name] = err.Error()
+			return
+		}
+		info.Checks[name] = "ok"
+	}
+
+	if s.draining.Load() {
+		check("shutdown", errors.New("server is shutting down"))
+	}
+
+	_, err := s.rootFS.ReadDir(".")
+	check("root", err)
+
+	for _, name := range []string{"index.html", "player.html"} {
+		buf, err := dist.Embed.ReadFile(name)
+		if err == nil {
+			_, err = template.New(name).Parse(string(buf))
+		}
+		check("template:"+name, err)
+	}
+	return info
+}
+
+func (c *handler) handleStatus() bool {
+	if !c.opts.Status || c.requestURL != "/" || c.Request.URL.RawQuery != QueryVFSStatus {
+		return false
+	}
+	// the status shows paths on disk and the version
+	if !c.isLocalClient() {
+		c.abortWithError(http.StatusForbidden, "status: forbidden")
+		return true
+	}
+
+	status := c.status()
+	c.Header("Cache-Control", "no-store")
+	if !strings.Contains(c.GetHeader("Accept"), "text/html") {
+		c.writeJSON(http.StatusOK, status, true)
+		return true
+	}
+
+	c.Header("Content-Type", "text/html; charset=utf-8")
+	c.Status(http.StatusOK)
+	err := statusTemplate.Execute(c.Writer, status)
+	if err != nil {
+		c.T("server/status").Errf("Unable to render status.html: %v", err)
+	}
+	return true
+}
+
+func (c *handler) handleMetrics() bool {
+	if c.opts.MetricsHandler == nil || c.requestURL != "/" || c.Request.URL.RawQuery != QueryVFSMetrics {
+		return false
+	}
+	// the metrics show the traffic and runtime internals
+	if !c.isLocalClient() {
+		c.abortWithError(http.StatusForbidden, "metrics: forbidden")
+		return true
+	}
+	c.opts.MetricsHandler.ServeHTTP(c.Writer, c.Request)
+	return true
+}
+
+// isLocalClient reports whether the client is the host itself or one of its proxies,
+// a peer on a unix socket without X-Forwarded-For is a local process
+func (c *handler) isLocalClient() bool {
+	clientIP := httpx.ClientIP(c.Request, c.opts.TrustedProxies)
+	if isLoopback(clientIP) || (clientIP == "" && httpx.IsUnixSocket(c.Request)) || httpx.IsTrusted(clientIP, c.opts.TrustedProxies) {
+		return true
+	}
+	c.T("server/status").Dbgf("Internal endpoint denied to client: %s", clientIP)
+	return false
+}
+
+func isLoopback(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	return err == nil && addr.Unmap().IsLoopback()
+}
+
+func (s *serverConfig) status() dto.StatusInfo {
+	uptime := time.Since(s.createdAt)
+	status := dto.StatusInfo{
+		AppName:         meta.AppName,
+		Version:         meta.AppVersion,
+		BuildTime:       meta.BuildTimestamp,
+		BuildMode:       meta.BuildMode,
+		ServerName:      s.opts.ServerName,
+		GoVersion:       runtime.Version(),
+		StartedAt:       s.createdAt.Format(time.RFC3339),
+		UptimeSeconds:   int64(uptime.Seconds()),
+		Uptime:          uptime.Truncate(time.Second).String(),
+		OpenConnections: s.openConnections.Load(),
+		Mounts:          mounts(s.rootFS),
+	}
+
+	diskPath := localPath(s.rootFS)
+	if diskPath == "" {
+		return status
+	}
+	usage, err := diskstat.Get(diskPath)
+	if err == nil {
+		status.Disk = &dto.DiskInfo{
+			Path:        diskPath,
+			Total:       usage.Total,
+			Free:        usage.Free,
+			Used:        usage.Used(),
+			UsedPercent: usage.UsedPercent(),
+		}
+	}
+	return status
+}
+
+func mounts(b backend.Backend) []dto.MountInfo {
+	if o, ok := b.(interface{ Layers() []backend.Backend }); ok {
+		infos := make([]dto.MountInfo, 0)
+		for _, layer := range o.Layers() {
+			infos = append(infos, mounts(layer)...)
+		}
+		return infos
+	}
+	return []dto.MountInfo{{Path: "/", Source: b.Source(), Backend: b.Kind()}}
+}
+
+// localPath is the local directory of the first backend on disk, used for disk usage
+func localPath(b backend.Backend) string {
+	for _, m := range mounts(b) {
+		switch m.Backend {
+		case backend.KindDir:
+			return m.Source
+		case backend.KindZip, backend.KindTar:
+			return filepath.Dir(m.Source)
+		}
+	}
+	return ""
+}
+
+func formatBytes(n uint64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := uint64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
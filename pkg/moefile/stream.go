@@ -0,0 +1,138 @@
+package moefile
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"net/http"
+	"strings"
+	"time"
+
+	"moefile/pkg/backend"
+	"moefile/pkg/dto"
+)
+
+const (
+	// QueryLong adds the long fields to NDJSON lines, as ls -l does
+	QueryLong = "l"
+
+	streamBatchSize = 256
+)
+
+type flusher interface {
+	Flush() error
+}
+
+// textLine leaves out names with a line break, which would be read as several entries, NDJSON lists them
+func textLine(c *handler, f dto.FileInfo) ([]byte, error) {
+	if strings.ContainsAny(f.FileName, "\r\n") {
+		c.T("server/xml").Dbgf("Skipping name with a line break in text listing: %q", f.FileName)
+		return nil, nil
+	}
+	if f.IsDirectory {
+		return []byte(f.FileName + "/\n"), nil
+	}
+	return []byte(f.FileName + "\n"), nil
+}
+
+func ndjsonLine(c *handler, f dto.FileInfo) ([]byte, error) {
+	return f.ToNDJSON(c.listing.long)
+}
+
+func (f listingFormat) renderLines(c *handler, info dto.DirInfo) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	for _, file := range info.Files {
+		line, err := f.line(c, file)
+		if err != nil {
+			c.T("server/xml").Errf("Unable to render %s line: %v", f.name, err)
+			return nil, err
+		}
+		buf.Write(line)
+	}
+	return buf.Bytes(), nil
+}
+
+// streamListing writes the lines while the directory is read, so the entries are filtered but in directory order.
+// There are no validators, as they are known only at the end.
+func (c *handler) streamListing(f listingFormat, query listingQuery) {
+	span, end := c.span("stream " + f.name)
+	defer end()
+
+	start := time.Now()
+	defer c.opts.Metrics.observeDirWalk(DirWalkListing, start)
+
+	file, err := c.rootFS.Open(c.relPath)
+	if err != nil {
+		c.abortWithError(http.StatusInternalServerError, "xml: server error")
+		return
+	}
+	//nolint:errcheck
+	defer file.Close()
+	dir, ok := file.(fs.ReadDirFile)
+	if !ok {
+		c.T("server/xml").Errf("Unable to read directory <(wwwroot)/%s>: not a ReadDirFile", c.relPath)
+		c.abortWithError(http.StatusInternalServerError, "xml: server error")
+		return
+	}
+
+	// the first batch is read before the headers, so that an error is still reported by the status
+	entries, err := dir.ReadDir(streamBatchSize)
+	if err != nil && !errors.Is(err, io.EOF) {
+		c.T("server/xml").Errf("Unable to read directory <(wwwroot)/%s>: %v", c.relPath, err)
+		c.abortWithError(http.StatusInternalServerError, "xml: server error")
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	w, err := c.newEncodedWriter(f.contentType)
+	if err != nil {
+		c.T("server/xml").Errf("Unable to create %s stream: %v", f.name, err)
+		c.Status(http.StatusInternalServerError)
+		return
+	}
+	//nolint:errcheck
+	defer w.Close()
+
+	info := dto.NewFSDirInfo(c.opts.ServerName, strings.TrimPrefix(c.requestURL, "/"))
+	count := 0
+	for len(entries) > 0 {
+		info.Files = info.Files[:0]
+		for _, entry := range entries {
+			stat, err := entry.Info()
+			if err != nil {
+				c.T("server/xml").Dbgf("Unable to stat <(wwwroot)/%s/%s>: %v", c.relPath, entry.Name(), err)
+				continue
+			}
+			info.AddFSFile(stat)
+			if c.opts.ShowSources {
+				info.Files[len(info.Files)-1].Source = backend.EntrySource(stat)
+			}
+		}
+
+		query.apply(&info)
+		buf, err := f.renderLines(c, info)
+		if err != nil {
+			return
+		}
+		_, err = w.Write(buf)
+		if err != nil {
+			c.T("server/xml").Dbgf("Unable to write %s stream: %v", f.name, err)
+			return
+		}
+		if fw, ok := w.(flusher); ok {
+			//nolint:errcheck
+			fw.Flush()
+		}
+		c.Writer.Flush()
+		count += len(info.Files)
+
+		entries, err = dir.ReadDir(streamBatchSize)
+		if err != nil && !errors.Is(err, io.EOF) {
+			// the status is sent already, the listing ends early
+			c.T("server/xml").Errf("Unable to read directory <(wwwroot)/%s>: %v", c.relPath, err)
+			break
+		}
+	}
+	span.SetAttr("moefile.entries", count)
+}
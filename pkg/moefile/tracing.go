@@ -0,0 +1,54 @@
+package moefile
+
+import (
+	"net/http"
+
+	"moefile/pkg/httpx"
+	"moefile/pkg/trace"
+)
+
+// startTrace starts the span of the request, the returned function ends it with the handler name
+func (c *handler) startTrace() func(name string) {
+	if c.opts.Tracer == nil {
+		return func(string) {}
+	}
+
+	ctx := c.ctx
+	if c.isTrustedProxy(c.Request) {
+		if remote, ok := trace.ParseTraceparent(c.GetHeader(trace.HeaderTraceparent)); ok {
+			remote.TraceState = c.GetHeader(trace.HeaderTracestate)
+			ctx = trace.ContextWithRemote(ctx, remote)
+		}
+	}
+
+	ctx, span := c.opts.Tracer.Start(ctx, "HTTP "+c.Request.Method, trace.KindServer)
+	span.SetAttr("http.request.method", c.Request.Method)
+	span.SetAttr("url.path", c.Request.URL.Path)
+	span.SetAttr("url.query", c.Request.URL.RawQuery)
+	span.SetAttr("client.address", httpx.ClientIP(c.Request, c.opts.TrustedProxies))
+	span.SetAttr("user_agent.original", c.Request.UserAgent())
+	span.SetAttr("moefile.request_id", c.requestID)
+	c.ctx = ctx
+	c.Request = c.Request.WithContext(ctx)
+
+	return func(name string) {
+		status := c.Writer.Status()
+		span.SetAttr("http.response.status_code", status)
+		span.SetAttr("moefile.handler", name)
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(trace.StatusError, http.StatusText(status))
+		}
+		span.Finish()
+	}
+}
+
+// span starts a child of the current span, the returned function ends it and restores the parent
+func (c *handler) span(name string) (*trace.Span, func()) {
+	parent := c.ctx
+	ctx, span := c.opts.Tracer.Start(parent, name, trace.KindInternal)
+	c.ctx = ctx
+	return span, func() {
+		span.Finish()
+		c.ctx = parent
+	}
+}
@@ -0,0 +1,40 @@
+package moefile
+
+import (
+	"path"
+	"path/filepath"
+)
+
+type urlInfo struct {
+	requestURL string
+	relPath    string
+	ok         bool
+}
+
+func (c *handler) resolve(url string) (result urlInfo) {
+	span, end := c.span("resolve")
+	defer end()
+	span.SetAttr("moefile.url", url)
+
+	c.T("url").Dbgf("HTTP_URL_REQUEST:  %s", url)
+
+	url = path.Clean("./" + url)
+	c.T("url").Dbgf(" ->  URL_CLEAN:    %s", url)
+
+	url, err := filepath.Localize(url)
+	c.T("url").Dbgf(" ->  URL_PLATFORM: %s", url)
+	if err != nil {
+		c.T("url").Errf("Invalid URL in request <%s>: %s", url, err)
+		return
+	}
+
+	cleanURL := filepath.Join("/", url)
+	c.T("url").Dbgf(" ->  URL_REAL:     %s", cleanURL)
+
+	result = urlInfo{
+		requestURL: cleanURL,
+		relPath:    url,
+		ok:         true,
+	}
+	return
+}
@@ -1,4 +1,4 @@
-package server
+package moefile
 
 import (
 	"bytes"
@@ -10,26 +10,26 @@ import (
 	"slices"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/baobao1270/slang"
 
 	"moefile/dist"
-	"moefile/internal/log"
+	"moefile/pkg/backend"
 	"moefile/pkg/dto"
 )
 
-func (s *serverConfig) readFSDir(name string) ([]fs.DirEntry, error) {
-	vfs, ok := s.rootFS.(fs.ReadDirFS)
-	if !ok {
-		log.T("server/xml").Errf("Unsupported filesystemc cast to fs.ReadDirFS: %T", s.rootFS)
-	}
+func (c *handler) readFSDir(name string) ([]fs.DirEntry, error) {
+	span, end := c.span("readFSDir")
+	defer end()
+	span.SetAttr("moefile.path", name)
 
-	return vfs.ReadDir(name)
+	return c.rootFS.ReadDir(name)
 }
 
-func (s *serverConfig) statFSDir(name string) ([]fs.FileInfo, error) {
+func (c *handler) statFSDir(name string) ([]fs.FileInfo, error) {
 	files := make([]fs.FileInfo, 0)
-	entries, err := s.readFSDir(name)
+	entries, err := c.readFSDir(name)
 	if err != nil {
 		return files, err
 	}
@@ -45,28 +45,58 @@ func (s *serverConfig) statFSDir(name string) ([]fs.FileInfo, error) {
 	return files, nil
 }
 
-func (s *serverConfig) createS3XMLFromFSDir(url, path string) ([]byte, error) {
-	res := dto.NewFSDirInfo(s.app.ServerName, strings.TrimPrefix(url, "/"))
-	dir, err := s.statFSDir(path)
+func (c *handler) createDirInfoFromFSDir(url, path string) (dto.DirInfo, error) {
+	res := dto.NewFSDirInfo(c.opts.ServerName, strings.TrimPrefix(url, "/"))
+	start := time.Now()
+	dir, err := c.statFSDir(path)
+	c.opts.Metrics.observeDirWalk(DirWalkListing, start)
 	if err != nil {
-		return nil, err
+		return res, err
 	}
 
 	for _, stat := range dir {
 		res.AddFSFile(stat)
+		if c.opts.ShowSources {
+			res.Files[len(res.Files)-1].Source = backend.EntrySource(stat)
+		}
 	}
+	return res, nil
+}
 
-	buf, err := res.ToS3XML(s.app.XMLIndent)
+func (c *handler) createS3XMLFromDirInfo(res dto.DirInfo) ([]byte, error) {
+	span, end := c.span("render ListBucketResult")
+	defer end()
+	span.SetAttr("moefile.entries", len(res.Files))
+
+	buf, err := res.ToS3XML(c.opts.XMLIndent)
 	if err != nil {
-		log.T("server/xml").Errf("Unable to marshal ListBucketResult: %s", err)
+		c.T("server/xml").Errf("Unable to marshal ListBucketResult: %s", err)
 		return nil, err
 	}
 
 	return buf, nil
 }
 
-func (s *serverConfig) searchPlayerData(requestURL string) (dto.PlayerData, error) {
-	log.T("server/player/search").Dbgf("-------- enter searchPlayerData --------")
+func (c *handler) createJSONFromDirInfo(res dto.DirInfo) ([]byte, error) {
+	span, end := c.span("render ListBucketResult JSON")
+	defer end()
+	span.SetAttr("moefile.entries", len(res.Files))
+
+	buf, err := res.ToJSON(c.opts.XMLIndent, c.link("/")+"?"+QueryVFSSchema)
+	if err != nil {
+		c.T("server/xml").Errf("Unable to marshal ListBucketResult to JSON: %s", err)
+		return nil, err
+	}
+
+	return buf, nil
+}
+
+func (c *handler) searchPlayerData(requestURL string) (dto.PlayerData, error) {
+	span, end := c.span("searchPlayerData")
+	defer end()
+	span.SetAttr("moefile.url", requestURL)
+
+	c.T("server/player/search").Dbgf("-------- enter searchPlayerData --------")
 	if !strings.HasPrefix(requestURL, "/") {
 		requestURL = "/" + requestURL
 	}
@@ -83,15 +113,18 @@ func (s *serverConfig) searchPlayerData(requestURL string) (dto.PlayerData, erro
 	baseName := filepath.Base(requestURL)
 	title := strings.TrimSuffix(baseName, filepath.Ext(baseName))
 	data := dto.PlayerData{
-		Subs: make([]dto.PlayerSub, 0),
+		BasePath: c.link("/"),
+		Subs:     make([]dto.PlayerSub, 0),
 	}
-	dir, err := s.readFSDir(pathDir)
+	start := time.Now()
+	dir, err := c.readFSDir(pathDir)
+	c.opts.Metrics.observeDirWalk(DirWalkPlayer, start)
 	if err != nil {
 		return data, nil
 	}
 
 	for _, entry := range dir {
-		entryURL := filepath.Join(urlDir, entry.Name())
+		entryURL := c.link(filepath.ToSlash(filepath.Join(urlDir, entry.Name())))
 		if entry.IsDir() {
 			continue
 		}
@@ -129,8 +162,8 @@ func (s *serverConfig) searchPlayerData(requestURL string) (dto.PlayerData, erro
 		}
 	}
 
-	log.T("server/player/search").Dbgf("PlayerData: %+v", data)
-	log.T("server/player/search").Dbgf("-------- leave searchPlayerData --------")
+	c.T("server/player/search").Dbgf("PlayerData: %+v", data)
+	c.T("server/player/search").Dbgf("-------- leave searchPlayerData --------")
 	return data, nil
 }
 
@@ -162,7 +195,7 @@ func defaultSub(url string) dto.PlayerSub {
 		Lang:     "und",
 		URL:      url,
 		LangName: "CC",
-		LangInfo: slang.Lang{
+		LangInfo: dto.Lang{
 			Name:       "und",
 			BCP47:      "zz",
 			WinID:      "ZZZ",
@@ -213,32 +246,35 @@ func createSub(prefix, name, url string) dto.PlayerSub {
 		Lang:     lang.BCP47,
 		URL:      url,
 		LangName: strings.ToUpper(langName),
-		LangInfo: *lang,
+		LangInfo: dto.NewLang(*lang),
 	}
 }
 
-func renderPlayerData(data dto.PlayerData) (io.Reader, error) {
+func (c *handler) renderPlayerData(data dto.PlayerData) (io.Reader, error) {
+	_, end := c.span("render player.html")
+	defer end()
+
 	tmtmplBuf, err := dist.Embed.ReadFile("player.html")
 	if err != nil {
-		log.T("server/player").Errf("Unable to read player.html: %s", err)
+		c.T("server/player").Errf("Unable to read player.html: %s", err)
 		return nil, err
 	}
 
 	dataBuf, err := json.Marshal(data)
 	if err != nil {
-		log.T("server/player").Errf("Unable to marshal danmaku and subtitles (PlayerData): %v", err)
+		c.T("server/player").Errf("Unable to marshal danmaku and subtitles (PlayerData): %v", err)
 	}
 
 	tmpl, err := template.New("player.html").Parse(string(tmtmplBuf))
 	if err != nil {
-		log.T("server/player").Errf("Unable to create template with player.html: %v", err)
+		c.T("server/player").Errf("Unable to create template with player.html: %v", err)
 		return nil, err
 	}
 
 	outBuf := new(bytes.Buffer)
 	err = tmpl.Execute(outBuf, string(dataBuf))
 	if err != nil {
-		log.T("server/player").Errf("Unable to render player.html with data <%s>: %v", string(dataBuf), err)
+		c.T("server/player").Errf("Unable to render player.html with data <%s>: %v", string(dataBuf), err)
 		return nil, err
 	}
 
@@ -0,0 +1,271 @@
+package proxyproto
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultTimeout = 5 * time.Second
+
+	v1Prefix    = "PROXY "
+	v1MaxLength = 107
+	v2HeaderLen = 16
+)
+
+var (
+	v2Signature = []byte("\r\n\r\n\x00\r\nQUIT\n")
+
+	ErrInvalidHeader = errors.New("proxyproto: invalid header")
+)
+
+// Listener reads the PROXY protocol header of connections from trusted peers,
+// the header is optional so that health checks can connect directly
+type Listener struct {
+	net.Listener
+	Trusted func(netip.Addr) bool
+	Timeout time.Duration
+}
+
+// Conn reports the addresses in the PROXY header, which is read on the first Read, RemoteAddr or LocalAddr
+type Conn struct {
+	net.Conn
+	reader  *bufio.Reader
+	timeout time.Duration
+	once    sync.Once
+	remote  net.Addr
+	local   net.Addr
+	err     error
+
+	// deadline is the read deadline set by the server, restored after the header is read
+	mu       sync.Mutex
+	deadline time.Time
+}
+
+func NewListener(l net.Listener, trusted []netip.Prefix) *Listener {
+	return &Listener{
+		Listener: l,
+		Trusted: func(addr netip.Addr) bool {
+			for _, prefix := range trusted {
+				if prefix.Contains(addr) {
+					return true
+				}
+			}
+			return false
+		},
+		Timeout: DefaultTimeout,
+	}
+}
+
+func (l *Listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	// peers on unix sockets are local proxies, they are trusted
+	switch addr := conn.RemoteAddr().(type) {
+	case *net.UnixAddr:
+	case *net.TCPAddr:
+		if l.Trusted == nil || !l.Trusted(addr.AddrPort().Addr().Unmap()) {
+			return conn, nil
+		}
+	default:
+		return conn, nil
+	}
+	return &Conn{Conn: conn, reader: bufio.NewReader(conn), timeout: l.Timeout}, nil
+}
+
+func (c *Conn) init() {
+	c.once.Do(func() {
+		if c.timeout > 0 {
+			c.mu.Lock()
+			deadline := time.Now().Add(c.timeout)
+			if !c.deadline.IsZero() && c.deadline.Before(deadline) {
+				deadline = c.deadline
+			}
+			//nolint:errcheck
+			c.Conn.SetReadDeadline(deadline)
+			c.mu.Unlock()
+			defer func() {
+				c.mu.Lock()
+				//nolint:errcheck
+				c.Conn.SetReadDeadline(c.deadline)
+				c.mu.Unlock()
+			}()
+		}
+		c.remote, c.local, c.err = readHeader(c.reader)
+	})
+}
+
+func (c *Conn) SetDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deadline = t
+	return c.Conn.SetDeadline(t)
+}
+
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.deadline = t
+	return c.Conn.SetReadDeadline(t)
+}
+
+func (c *Conn) Read(p []byte) (int, error) {
+	c.init()
+	if c.err != nil {
+		return 0, c.err
+	}
+	return c.reader.Read(p)
+}
+
+func (c *Conn) RemoteAddr() net.Addr {
+	c.init()
+	if c.remote != nil {
+		return c.remote
+	}
+	return c.Conn.RemoteAddr()
+}
+
+func (c *Conn) LocalAddr() net.Addr {
+	c.init()
+	if c.local != nil {
+		return c.local
+	}
+	return c.Conn.LocalAddr()
+}
+
+// readHeader returns nil addresses when there is no header or the header carries no address
+func readHeader(r *bufio.Reader) (remote, local net.Addr, err error) {
+	// idle or closed connections are left to the HTTP server
+	first, err := r.Peek(1)
+	if err != nil {
+		return nil, nil, nil
+	}
+
+	switch first[0] {
+	case v1Prefix[0]:
+		buf, err := r.Peek(len(v1Prefix))
+		if err != nil || string(buf) != v1Prefix {
+			return nil, nil, nil
+		}
+		return readV1(r)
+	case v2Signature[0]:
+		buf, err := r.Peek(len(v2Signature))
+		if err != nil || !bytes.Equal(buf, v2Signature) {
+			return nil, nil, nil
+		}
+		return readV2(r)
+	}
+	return nil, nil, nil
+}
+
+// readV1 parses "PROXY TCP4 <src> <dst> <sport> <dport>\r\n"
+func readV1(r *bufio.Reader) (remote, local net.Addr, err error) {
+	line := make([]byte, 0, v1MaxLength)
+	for {
+		b, err := r.ReadByte()
+		if errors.Is(err, io.EOF) {
+			return nil, nil, io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		line = append(line, b)
+		if b == '\n' {
+			break
+		}
+		if len(line) >= v1MaxLength {
+			return nil, nil, ErrInvalidHeader
+		}
+	}
+	if !bytes.HasSuffix(line, []byte("\r\n")) {
+		return nil, nil, ErrInvalidHeader
+	}
+
+	fields := strings.Fields(string(line[:len(line)-2]))
+	if len(fields) >= 2 && fields[1] == "UNKNOWN" {
+		return nil, nil, nil
+	}
+	if len(fields) != 6 || (fields[1] != "TCP4" && fields[1] != "TCP6") {
+		return nil, nil, ErrInvalidHeader
+	}
+	src, err := parseV1Addr(fields[2], fields[4])
+	if err != nil {
+		return nil, nil, err
+	}
+	dst, err := parseV1Addr(fields[3], fields[5])
+	if err != nil {
+		return nil, nil, err
+	}
+	return src, dst, nil
+}
+
+func parseV1Addr(ip, port string) (net.Addr, error) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidHeader, err)
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidHeader, err)
+	}
+	return net.TCPAddrFromAddrPort(netip.AddrPortFrom(addr, uint16(p))), nil
+}
+
+// readV2 parses the binary header, the TLVs are skipped
+func readV2(r *bufio.Reader) (remote, local net.Addr, err error) {
+	header := make([]byte, v2HeaderLen)
+	_, err = io.ReadFull(r, header)
+	if err != nil {
+		return nil, nil, err
+	}
+	if header[12]>>4 != 2 {
+		return nil, nil, ErrInvalidHeader
+	}
+	command := header[12] & 0x0f
+	family := header[13]
+	body := make([]byte, binary.BigEndian.Uint16(header[14:16]))
+	_, err = io.ReadFull(r, body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// LOCAL is sent by the proxy itself, such as health checks
+	if command == 0 {
+		return nil, nil, nil
+	}
+	if command != 1 {
+		return nil, nil, ErrInvalidHeader
+	}
+
+	var size int
+	switch family {
+	case 0x11: // TCP over IPv4
+		size = 4
+	case 0x21: // TCP over IPv6
+		size = 16
+	default:
+		return nil, nil, nil
+	}
+	if len(body) < size*2+4 {
+		return nil, nil, ErrInvalidHeader
+	}
+	srcIP, _ := netip.AddrFromSlice(body[:size])
+	dstIP, _ := netip.AddrFromSlice(body[size : size*2])
+	srcPort := binary.BigEndian.Uint16(body[size*2:])
+	dstPort := binary.BigEndian.Uint16(body[size*2+2:])
+	src := net.TCPAddrFromAddrPort(netip.AddrPortFrom(srcIP, srcPort))
+	dst := net.TCPAddrFromAddrPort(netip.AddrPortFrom(dstIP, dstPort))
+	return src, dst, nil
+}
@@ -0,0 +1,179 @@
+package proxyproto_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/netip"
+	"os"
+	"testing"
+	"time"
+
+	"moefile/pkg/proxyproto"
+)
+
+func v2Header(command, family byte, body []byte) []byte {
+	buf := []byte("\r\n\r\n\x00\r\nQUIT\n")
+	buf = append(buf, 0x20|command, family)
+	buf = binary.BigEndian.AppendUint16(buf, uint16(len(body)))
+	return append(buf, body...)
+}
+
+func v2TCP4() []byte {
+	body := []byte{192, 0, 2, 1, 192, 0, 2, 2}
+	body = binary.BigEndian.AppendUint16(body, 1234)
+	body = binary.BigEndian.AppendUint16(body, 80)
+	return v2Header(1, 0x11, body)
+}
+
+func v2TCP6() []byte {
+	src, dst := netip.MustParseAddr("2001:db8::1").As16(), netip.MustParseAddr("2001:db8::2").As16()
+	body := append(src[:], dst[:]...)
+	body = binary.BigEndian.AppendUint16(body, 1234)
+	body = binary.BigEndian.AppendUint16(body, 443)
+	return v2Header(1, 0x21, body)
+}
+
+// accept sends data from a client over loopback TCP, and returns both sides of the connection
+func accept(t *testing.T, trusted bool, data []byte) (server, client net.Conn) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	l := &proxyproto.Listener{
+		Listener: ln,
+		Trusted:  func(netip.Addr) bool { return trusted },
+		Timeout:  time.Second,
+	}
+
+	client, err = net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { client.Close() })
+	_, err = client.Write(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = client.(*net.TCPConn).CloseWrite()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	server, err = l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { server.Close() })
+	return server, client
+}
+
+func TestListener(t *testing.T) {
+	tests := []struct {
+		name    string
+		trusted bool
+		data    []byte
+		remote  string
+		local   string
+		body    string
+		err     bool
+	}{
+		{name: "v1 tcp4", trusted: true, data: []byte("PROXY TCP4 192.0.2.1 192.0.2.2 1234 80\r\nGET /"), remote: "192.0.2.1:1234", local: "192.0.2.2:80", body: "GET /"},
+		{name: "v1 tcp6", trusted: true, data: []byte("PROXY TCP6 2001:db8::1 2001:db8::2 1234 443\r\nGET /"), remote: "[2001:db8::1]:1234", local: "[2001:db8::2]:443", body: "GET /"},
+		{name: "v1 unknown", trusted: true, data: []byte("PROXY UNKNOWN\r\nGET /"), body: "GET /"},
+		{name: "v1 invalid address", trusted: true, data: []byte("PROXY TCP4 192.0.2.x 192.0.2.2 1234 80\r\nGET /"), err: true},
+		{name: "v1 without crlf", trusted: true, data: []byte("PROXY TCP4 192.0.2.1 192.0.2.2 1234 80\nGET /"), err: true},
+		{name: "v1 truncated", trusted: true, data: []byte("PROXY TCP4 192.0.2.1 192.0"), err: true},
+		{name: "v1 too long", trusted: true, data: append([]byte("PROXY TCP4 "), bytes.Repeat([]byte("1"), 120)...), err: true},
+		{name: "v2 proxy tcp4", trusted: true, data: append(v2TCP4(), "GET /"...), remote: "192.0.2.1:1234", local: "192.0.2.2:80", body: "GET /"},
+		{name: "v2 proxy tcp6", trusted: true, data: append(v2TCP6(), "GET /"...), remote: "[2001:db8::1]:1234", local: "[2001:db8::2]:443", body: "GET /"},
+		{name: "v2 local", trusted: true, data: append(v2Header(0, 0x00, nil), "GET /"...), body: "GET /"},
+		{name: "v2 unspecified family", trusted: true, data: append(v2Header(1, 0x00, []byte{1, 2, 3}), "GET /"...), body: "GET /"},
+		{name: "v2 truncated header", trusted: true, data: v2TCP4()[:14], err: true},
+		{name: "v2 truncated body", trusted: true, data: v2TCP4()[:20], err: true},
+		{name: "v2 short body", trusted: true, data: v2Header(1, 0x11, []byte{192, 0, 2, 1}), err: true},
+		{name: "v2 bad version", trusted: true, data: append(v2Header(1, 0x11, nil)[:12], 0x11, 0x11, 0, 0), err: true},
+		{name: "v2 bad command", trusted: true, data: v2Header(2, 0x11, nil), err: true},
+		{name: "no header", trusted: true, data: []byte("GET /"), body: "GET /"},
+		{name: "untrusted v1", data: []byte("PROXY TCP4 192.0.2.1 192.0.2.2 1234 80\r\nGET /"), body: "PROXY TCP4 192.0.2.1 192.0.2.2 1234 80\r\nGET /"},
+		{name: "untrusted v2", data: append(v2TCP4(), "GET /"...), body: string(append(v2TCP4(), "GET /"...))},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, client := accept(t, tt.trusted, tt.data)
+
+			body, err := io.ReadAll(conn)
+			if tt.err {
+				if err == nil {
+					t.Fatalf("read %q, want error", body)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(body) != tt.body {
+				t.Errorf("body = %q, want %q", body, tt.body)
+			}
+
+			remote, local := tt.remote, tt.local
+			if remote == "" {
+				remote, local = client.LocalAddr().String(), client.RemoteAddr().String()
+			}
+			if got := conn.RemoteAddr().String(); got != remote {
+				t.Errorf("RemoteAddr = %s, want %s", got, remote)
+			}
+			if got := conn.LocalAddr().String(); got != local {
+				t.Errorf("LocalAddr = %s, want %s", got, local)
+			}
+		})
+	}
+}
+
+func TestConnKeepsReadDeadline(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	l := proxyproto.NewListener(ln, []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")})
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer client.Close()
+	_, err = client.Write([]byte("PROXY TCP4 192.0.2.1 192.0.2.2 1234 80\r\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// the deadline set by the server, such as ReadHeaderTimeout, outlives the header
+	err = conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if err != nil {
+		t.Fatal(err)
+	}
+	done := make(chan error, 1)
+	go func() {
+		_, err := conn.Read(make([]byte, 1))
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if !errors.Is(err, os.ErrDeadlineExceeded) {
+			t.Fatalf("Read error = %v, want deadline exceeded", err)
+		}
+	case <-time.After(3 * time.Second):
+		t.Fatal("Read does not time out, the deadline is cleared")
+	}
+}
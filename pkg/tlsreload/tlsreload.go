@@ -0,0 +1,195 @@
+package tlsreload
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const DefaultInterval = 10 * time.Second
+
+type ClientAuth int
+
+const (
+	ClientAuthOff ClientAuth = iota
+	ClientAuthOptional
+	ClientAuthRequire
+)
+
+var ErrNoClientCA = errors.New("client certificate verification requires a CA bundle")
+
+// Reloader keeps the certificate, key and client CA bundle in sync with the files on disk
+type Reloader struct {
+	CertFile   string
+	KeyFile    string
+	CAFile     string
+	ClientAuth ClientAuth
+	OnReload   func()
+	OnError    func(error)
+
+	mu       sync.RWMutex
+	cert     *tls.Certificate
+	clientCA *x509.CertPool
+	modTimes map[string]time.Time
+	stop     chan struct{}
+	stopOnce sync.Once
+}
+
+func ParseClientAuth(s string) (ClientAuth, error) {
+	switch s {
+	case "", "off":
+		return ClientAuthOff, nil
+	case "optional":
+		return ClientAuthOptional, nil
+	case "require":
+		return ClientAuthRequire, nil
+	}
+	return ClientAuthOff, fmt.Errorf("unknown client auth mode: %s", s)
+}
+
+func (a ClientAuth) String() string {
+	return [...]string{"off", "optional", "require"}[a]
+}
+
+func New(certFile, keyFile, caFile string, clientAuth ClientAuth) (*Reloader, error) {
+	if clientAuth != ClientAuthOff && caFile == "" {
+		return nil, ErrNoClientCA
+	}
+	r := &Reloader{
+		CertFile:   certFile,
+		KeyFile:    keyFile,
+		CAFile:     caFile,
+		ClientAuth: clientAuth,
+		stop:       make(chan struct{}),
+	}
+	err := r.Reload()
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *Reloader) Reload() error {
+	modTimes, err := r.stat()
+	if err != nil {
+		return err
+	}
+	cert, err := tls.LoadX509KeyPair(r.CertFile, r.KeyFile)
+	if err != nil {
+		return err
+	}
+
+	var clientCA *x509.CertPool
+	if r.CAFile != "" {
+		buf, err := os.ReadFile(r.CAFile)
+		if err != nil {
+			return err
+		}
+		clientCA = x509.NewCertPool()
+		if !clientCA.AppendCertsFromPEM(buf) {
+			return fmt.Errorf("no certificates found in %s", r.CAFile)
+		}
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.clientCA = clientCA
+	r.modTimes = modTimes
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *Reloader) stat() (map[string]time.Time, error) {
+	modTimes := make(map[string]time.Time)
+	for _, name := range []string{r.CertFile, r.KeyFile, r.CAFile} {
+		if name == "" {
+			continue
+		}
+		info, err := os.Stat(name)
+		if err != nil {
+			return nil, err
+		}
+		modTimes[name] = info.ModTime()
+	}
+	return modTimes, nil
+}
+
+func (r *Reloader) changed() bool {
+	modTimes, err := r.stat()
+	if err != nil {
+		// the files may be replaced non-atomically, try again on the next tick
+		return false
+	}
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for name, t := range modTimes {
+		if !t.Equal(r.modTimes[name]) {
+			return true
+		}
+	}
+	return false
+}
+
+// Watch polls the files and reloads them when changed, until Close is called
+func (r *Reloader) Watch(interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-r.stop:
+				return
+			case <-ticker.C:
+			}
+			if !r.changed() {
+				continue
+			}
+			err := r.Reload()
+			if err != nil {
+				if r.OnError != nil {
+					r.OnError(err)
+				}
+				continue
+			}
+			if r.OnReload != nil {
+				r.OnReload()
+			}
+		}
+	}()
+}
+
+func (r *Reloader) Close() {
+	r.stopOnce.Do(func() { close(r.stop) })
+}
+
+// TLSConfig returns a config which picks up the reloaded certificate and CA bundle on every handshake
+func (r *Reloader) TLSConfig() *tls.Config {
+	base := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		config := base.Clone()
+		config.GetConfigForClient = nil
+		config.Certificates = []tls.Certificate{*r.cert}
+		switch r.ClientAuth {
+		case ClientAuthOptional:
+			config.ClientAuth = tls.VerifyClientCertIfGiven
+			config.ClientCAs = r.clientCA
+		case ClientAuthRequire:
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+			config.ClientCAs = r.clientCA
+		}
+		return config, nil
+	}
+	return base
+}
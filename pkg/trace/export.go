@@ -0,0 +1,181 @@
+package trace
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const OTLPTracesPath = "/v1/traces"
+
+type OTLPExporter struct {
+	Endpoint string
+	Headers  map[string]string
+	Client   *http.Client
+}
+
+type StdoutExporter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+type otlpValue map[string]any
+
+type otlpAttr struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpSpan struct {
+	TraceID           string     `json:"traceId"`
+	SpanID            string     `json:"spanId"`
+	ParentSpanID      string     `json:"parentSpanId,omitempty"`
+	TraceState        string     `json:"traceState,omitempty"`
+	Name              string     `json:"name"`
+	Kind              SpanKind   `json:"kind"`
+	StartTimeUnixNano string     `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string     `json:"endTimeUnixNano"`
+	Attributes        []otlpAttr `json:"attributes,omitempty"`
+	Status            otlpStatus `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    StatusCode `json:"code,omitempty"`
+	Message string     `json:"message,omitempty"`
+}
+
+// NewOTLPExporter exports spans with OTLP/HTTP in JSON encoding, endpoint is like http://localhost:4318
+func NewOTLPExporter(endpoint string, headers map[string]string) *OTLPExporter {
+	endpoint = strings.TrimSuffix(endpoint, "/")
+	if !strings.HasSuffix(endpoint, OTLPTracesPath) {
+		endpoint += OTLPTracesPath
+	}
+	return &OTLPExporter{
+		Endpoint: endpoint,
+		Headers:  headers,
+		Client:   &http.Client{},
+	}
+}
+
+func (e *OTLPExporter) Export(ctx context.Context, service string, spans []*Span) error {
+	body, err := json.Marshal(otlpRequest(service, spans))
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := e.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	//nolint:errcheck
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("otlp: export to %s failed with status %s", e.Endpoint, resp.Status)
+	}
+	return nil
+}
+
+func (e *OTLPExporter) Shutdown(context.Context) error {
+	e.Client.CloseIdleConnections()
+	return nil
+}
+
+func NewStdoutExporter(w io.Writer) *StdoutExporter {
+	return &StdoutExporter{w: w}
+}
+
+// Export writes one OTLP JSON span per line, so it can be read by humans and tools like jq
+func (e *StdoutExporter) Export(_ context.Context, _ string, spans []*Span) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	for _, span := range spans {
+		buf, err := json.Marshal(toOTLPSpan(span))
+		if err != nil {
+			return err
+		}
+		if _, err = e.w.Write(append(buf, '\n')); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (e *StdoutExporter) Shutdown(context.Context) error {
+	return nil
+}
+
+func otlpRequest(service string, spans []*Span) map[string]any {
+	otlpSpans := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		otlpSpans = append(otlpSpans, toOTLPSpan(span))
+	}
+	return map[string]any{
+		"resourceSpans": []any{map[string]any{
+			"resource": map[string]any{
+				"attributes": []otlpAttr{{Key: "service.name", Value: toOTLPValue(service)}},
+			},
+			"scopeSpans": []any{map[string]any{
+				"scope": map[string]any{"name": service},
+				"spans": otlpSpans,
+			}},
+		}},
+	}
+}
+
+func toOTLPSpan(s *Span) otlpSpan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	span := otlpSpan{
+		TraceID:           s.Context.TraceID.String(),
+		SpanID:            s.Context.SpanID.String(),
+		TraceState:        s.Context.TraceState,
+		Name:              s.Name,
+		Kind:              s.Kind,
+		StartTimeUnixNano: strconv.FormatInt(s.Start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.End.UnixNano(), 10),
+		Status:            otlpStatus{Code: s.Status, Message: s.StatusMessage},
+	}
+	if s.Parent.IsValid() {
+		span.ParentSpanID = s.Parent.String()
+	}
+	for _, attr := range s.Attrs {
+		span.Attributes = append(span.Attributes, otlpAttr{Key: attr.Key, Value: toOTLPValue(attr.Value)})
+	}
+	return span
+}
+
+func toOTLPValue(v any) otlpValue {
+	switch v := v.(type) {
+	case string:
+		return otlpValue{"stringValue": v}
+	case bool:
+		return otlpValue{"boolValue": v}
+	case int:
+		return otlpValue{"intValue": strconv.Itoa(v)}
+	case int64:
+		return otlpValue{"intValue": strconv.FormatInt(v, 10)}
+	case uint64:
+		return otlpValue{"intValue": strconv.FormatUint(v, 10)}
+	case float64:
+		return otlpValue{"doubleValue": v}
+	default:
+		return otlpValue{"stringValue": fmt.Sprint(v)}
+	}
+}
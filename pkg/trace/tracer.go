@@ -0,0 +1,158 @@
+package trace
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultQueueSize     = 2048
+	DefaultBatchSize     = 512
+	DefaultFlushInterval = 5 * time.Second
+)
+
+type Exporter interface {
+	Export(ctx context.Context, service string, spans []*Span) error
+	Shutdown(ctx context.Context) error
+}
+
+type Tracer struct {
+	Service     string
+	SampleRatio float64
+	OnError     func(err error)
+	exporter    Exporter
+	queue       chan *Span
+	flush       chan chan struct{}
+	done        chan struct{}
+	mu          sync.RWMutex
+	closed      bool
+}
+
+func NewTracer(service string, exporter Exporter) *Tracer {
+	t := &Tracer{
+		Service:     service,
+		SampleRatio: 1,
+		exporter:    exporter,
+		queue:       make(chan *Span, DefaultQueueSize),
+		flush:       make(chan chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go t.run()
+	return t
+}
+
+// Start creates a span as a child of the span in ctx. It is safe to call on a nil Tracer,
+// the returned span does nothing in that case.
+func (t *Tracer) Start(ctx context.Context, name string, kind SpanKind) (context.Context, *Span) {
+	if t == nil {
+		return ctx, nil
+	}
+
+	span := &Span{
+		tracer: t,
+		Name:   name,
+		Kind:   kind,
+		Start:  time.Now(),
+	}
+	if parent := SpanFromContext(ctx); parent != nil && parent.Context.IsValid() {
+		span.Context.TraceID = parent.Context.TraceID
+		span.Context.Sampled = parent.Context.Sampled
+		span.Context.TraceState = parent.Context.TraceState
+		span.Parent = parent.Context.SpanID
+	} else {
+		span.Context.TraceID = newTraceID()
+		span.Context.Sampled = sampled(span.Context.TraceID, t.SampleRatio)
+	}
+	span.Context.SpanID = newSpanID()
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+func (t *Tracer) Flush() {
+	if t == nil {
+		return
+	}
+	ack := make(chan struct{})
+	select {
+	case t.flush <- ack:
+		<-ack
+	case <-t.done:
+	}
+}
+
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	if t == nil {
+		return nil
+	}
+	t.mu.Lock()
+	if !t.closed {
+		t.closed = true
+		close(t.queue)
+	}
+	t.mu.Unlock()
+
+	select {
+	case <-t.done:
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	return t.exporter.Shutdown(ctx)
+}
+
+// enqueue drops the span when the queue is full or the tracer has been shut down
+func (t *Tracer) enqueue(s *Span) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if t.closed {
+		return
+	}
+	select {
+	case t.queue <- s:
+	default:
+	}
+}
+
+func (t *Tracer) run() {
+	defer close(t.done)
+	ticker := time.NewTicker(DefaultFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, DefaultBatchSize)
+	export := func() {
+		if len(batch) == 0 {
+			return
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := t.exporter.Export(ctx, t.Service, batch); err != nil && t.OnError != nil {
+			t.OnError(err)
+		}
+		batch = make([]*Span, 0, DefaultBatchSize)
+	}
+
+	for {
+		select {
+		case span, ok := <-t.queue:
+			if !ok {
+				export()
+				return
+			}
+			batch = append(batch, span)
+			if len(batch) >= DefaultBatchSize {
+				export()
+			}
+		case <-ticker.C:
+			export()
+		case ack := <-t.flush:
+			for len(t.queue) > 0 {
+				span, ok := <-t.queue
+				if !ok {
+					break
+				}
+				batch = append(batch, span)
+			}
+			export()
+			close(ack)
+		}
+	}
+}
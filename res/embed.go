@@ -9,3 +9,12 @@ var XSLT string
 
 //go:embed xml_header.xml
 var XMLHeader []byte
+
+//go:embed status.html
+var StatusHTML string
+
+//go:embed schema.json
+var Schema []byte
+
+//go:embed autoindex.html
+var AutoindexHTML string
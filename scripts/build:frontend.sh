@@ -12,3 +12,6 @@ for PAGE_NAME in res/tmpl/*.prod.html; do
   echo "Building $PAGE_NAME"
   bun run internal:tsc && bun run internal:vite build --mode production --emptyOutDir false
 done
+
+echo "Precompressing $DIST_DIR"
+go run ./cmd/precompress "$DIST_DIR"
@@ -64,7 +64,7 @@ import { Input } from '@/components/ui/input'
 import { Label } from '@/components/ui/label'
 import { Select } from '@/components/ui/select'
 import { Button } from '@/components/ui/button'
-import { DirectoryInfo, FileInfo, FileType, GetFileType } from '@/lib/directory'
+import { DirectoryInfo, FileInfo, FileType, GetFileType, joinBasePath } from '@/lib/directory'
 import Footer from '@/components/footer'
 import { encodeURIRFC3986 } from  '@/lib/utils'
 import './App.css'
@@ -123,6 +123,7 @@ function App() {
     const xml = parser.parseFromString(data, 'application/xml')
     const info: DirectoryInfo = {
       bucketName: XMLQuerySelector(xml, 'Name', DEFAULT_TITLE),
+      basePath: XMLQuerySelector(xml, 'BasePath', '/'),
       path: XMLQuerySelector(xml, 'Prefix', '/'),
       serverTimezoneOffset: XMLQuerySelector(xml, 'ServerTimezoneOffset', '+00:00'),
       files: Array.from(XMLQuerySelectorAll(xml, 'Contents')).map(file => ({
@@ -130,6 +131,7 @@ function App() {
         fileName: XMLQuerySelector(file, 'FileName', ''),
         lastModifiedUnix: parseInt(XMLQuerySelector(file, 'LastModifiedUnix', '0')),
         size: parseInt(XMLQuerySelector(file, 'Size', '0')),
+        source: XMLQuerySelector(file, 'Source', '') || undefined,
       }))
     }
     setDirectoryInfo(info)
@@ -252,7 +254,11 @@ function App() {
 
   function playUrl(filename: string) {
     const filePath = `${directoryInfo?.path.replace(/\/$/, '')}/${encodeURIRFC3986(filename)}`.replace(/^\//, '')
-    return `/?_/player/${filePath}`
+    return link(`?_/player/${filePath}`)
+  }
+
+  function link(path: string) {
+    return joinBasePath(directoryInfo?.basePath || '/', path)
   }
 
   return (
@@ -325,7 +331,7 @@ function App() {
         <Breadcrumb className="mb-1">
           <BreadcrumbList className="whitespace-nowrap overflow-x-auto flex-nowrap breadcrumbs">
             <BreadcrumbItem>
-              <BreadcrumbLink href="/">
+              <BreadcrumbLink href={link('')}>
                 <HomeIcon className="w-4 h-4" />
               </BreadcrumbLink>
             </BreadcrumbItem>
@@ -335,7 +341,7 @@ function App() {
                 {directoryInfo?.path.split('/').map((dir, index, dirs) => (
                   <BreadcrumbItem key={index}>
                     <ChevronRightIcon className="w-4 h-4" />
-                    <BreadcrumbLink href={`/${dirs.slice(0, index + 1).join('/')}`} className="flex items-center gap-1">
+                    <BreadcrumbLink href={link(dirs.slice(0, index + 1).join('/'))} className="flex items-center gap-1">
                       <FolderIcon className="w-4 h-4" />
                       {dir}
                     </BreadcrumbLink>
@@ -403,7 +409,7 @@ function App() {
                     {FILEICON_MAP[GetFileType(file)]}
                   </TableCell>
                   <TableCell className="truncate p-0">
-                    <a href={encodeURIRFC3986(file.fileName)}
+                    <a href={encodeURIRFC3986(file.fileName)} title={file.source}
                       className="truncate block pl-2 w-full h-8 flex items-center font-medium hover:text-blue-500">
                       <span className="truncate">{file.fileName}</span>
                     </a>
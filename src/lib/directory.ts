@@ -1,5 +1,6 @@
 export interface DirectoryInfo {
   bucketName: string;
+  basePath: string;
   path: string;
   files: FileInfo[];
   serverTimezoneOffset: string;
@@ -10,6 +11,11 @@ export interface FileInfo {
   lastModifiedUnix: number;
   isDirectory: boolean;
   size: number;
+  source?: string;
+}
+
+export function joinBasePath(basePath: string, path: string) : string {
+  return `${basePath.replace(/\/$/, '')}/${path.replace(/^\//, '')}`;
 }
 
 export type FileType = 'folder' | 'document' | 'image' | 'audio' | 'video' | 'code' | 'config' | 'archive' | 'binary' | 'file';
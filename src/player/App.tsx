@@ -15,6 +15,7 @@ import Footer from '@/components/footer'
 import NotFound from './NotFound'
 import { Button, buttonVariants } from '@/components/ui/button'
 import { t } from 'i18next'
+import { joinBasePath } from '@/lib/directory'
 import './App.css'
 
 
@@ -32,6 +33,7 @@ interface SubtitleData {
 }
 
 interface PlayerData {
+  basePath: string
   danmuku: string | null
   subtitles: SubtitleData[]
 }
@@ -109,6 +111,7 @@ function App() {
 
   function getPlayerData(): PlayerData | null {
     const result = {
+      basePath: '/',
       danmuku: null,
       subtitles: [],
     } as PlayerData
@@ -116,6 +119,7 @@ function App() {
     if (!playerData) { return null }
     try {
       const parsed = JSON.parse(playerData) as any
+      if (parsed.base_path && typeof parsed.base_path === 'string') { result.basePath = parsed.base_path }
       if (parsed.danmaku) { result.danmuku = parsed.danmaku }
       if (parsed.danmuku) { result.danmuku = parsed.danmuku }  // Handle multiple-translated versions
       if (parsed.subtitles && Array.isArray(parsed.subtitles)) {
@@ -149,11 +153,10 @@ function App() {
     console.log('Video URL', parsedVideoURL)
     if (!parsedVideoURL) { return setIs404(true) }
     if (!parsedVideoURL.startsWith('/')) { return setIs404(true) }
-    const escapedVideoURL = `${window.location.origin}${parsedVideoURL}`
+    const playerInfo = getPlayerData()
+    const escapedVideoURL = `${window.location.origin}${joinBasePath(playerInfo?.basePath || '/', parsedVideoURL)}`
     setVideoURL(escapedVideoURL)
 
-
-    const playerInfo = getPlayerData()
     const matchingSubtitle = getMetchedLenguageSubtitles(playerInfo?.subtitles || [])
     console.log('Matching subtitle is', matchingSubtitle)
 
@@ -221,7 +224,7 @@ function App() {
     <>
       <Toaster />
 
-      {is404 ? <NotFound /> :
+      {is404 ? <NotFound homeURL={getPlayerData()?.basePath || '/'} /> :
         <>
           <Card className={mobileMode ? 'mobile' : 'desktop'}>
             <CardContent>
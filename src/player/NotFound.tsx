@@ -9,7 +9,11 @@ import {
 import { buttonVariants } from '@/components/ui/button'
 import { HomeIcon } from 'lucide-react';
 
-function NotFound() {
+interface NotFoundProps {
+  homeURL: string
+}
+
+function NotFound({ homeURL }: NotFoundProps) {
   const { t } = useTranslation();
 
   return (
@@ -22,7 +26,7 @@ function NotFound() {
           <CardContent>
             <CardDescription dangerouslySetInnerHTML={{ __html: t('player.404.description') }} />
             <div className="flex justify-end items-center w-full">
-              <a href="/" className={buttonVariants({ variant: "outline" }) + ` mt-4`}>
+              <a href={homeURL} className={buttonVariants({ variant: "outline" }) + ` mt-4`}>
                 <HomeIcon className="w-4 h-4 mr-1" />
                 {t('player.404.back')}
               </a>